@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// errInvalidCursor is returned when a cursor from a URL can't be decoded.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor returns the position of a snippet in the snippet listing as
+// an opaque token for use in URLs.
+func encodeCursor(s *models.Snippet) string {
+	raw := fmt.Sprintf("%d.%d", s.Created.UnixNano(), s.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor turns a token made by encodeCursor back into a cursor.
+func decodeCursor(token string) (*models.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ".", 2)
+	if len(parts) != 2 {
+		return nil, errInvalidCursor
+	}
+	nsec, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id < 1 {
+		return nil, errInvalidCursor
+	}
+
+	return &models.Cursor{Created: time.Unix(0, nsec).UTC(), ID: id}, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+func TestCursor(t *testing.T) {
+	s := &models.Snippet{
+		ID:      42,
+		Created: time.Date(2020, 12, 17, 10, 0, 0, 123456789, time.UTC),
+	}
+
+	got, err := decodeCursor(encodeCursor(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &models.Cursor{Created: s.Created, ID: s.ID}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"Empty", ""},
+		{"Not base64", "!!!"},
+		{"No separator", "MTIz"},           // "123"
+		{"Non-numeric time", "eC40Mg"},     // "x.42"
+		{"Non-numeric ID", "MTIzLng"},      // "123.x"
+		{"Zero ID", "MTIzLjA"},             // "123.0"
+		{"Trailing garbage", "MTIzLjQyeA"}, // "123.42x"
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.token); err != errInvalidCursor {
+				t.Errorf("want %v; got %v", errInvalidCursor, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cedrickchee/snippetbox/pkg/forms"
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// An encrypted snippet is encrypted in the browser with AES-GCM before it's
+// sent, and the key is kept in the fragment of the snippet's link, which
+// browsers never send to the server. All the server ever sees is an
+// envelope of the form
+//
+//	v1.<IV>.<ciphertext>
+//
+// with the IV and the ciphertext (including the GCM tag) in unpadded
+// base64url. It's stored as the IV followed by the ciphertext.
+const (
+	envelopeVersion = "v1"
+	envelopeIVSize  = 12
+	envelopeTagSize = 16
+
+	// maxCiphertextSize is the largest ciphertext accepted, in bytes.
+	maxCiphertextSize = 16 * 1024
+)
+
+var (
+	errInvalidEnvelope  = errors.New("invalid envelope")
+	errEnvelopeTooLarge = errors.New("envelope too large")
+)
+
+// parseEnvelope checks the format of an envelope and returns the IV and
+// ciphertext in it, ready to be stored.
+func parseEnvelope(envelope string) ([]byte, error) {
+	// Anything longer than the largest possible envelope can be turned away
+	// without decoding it.
+	max := len(envelopeVersion) + 2 +
+		base64.RawURLEncoding.EncodedLen(envelopeIVSize) +
+		base64.RawURLEncoding.EncodedLen(maxCiphertextSize)
+	if len(envelope) > max {
+		return nil, errEnvelopeTooLarge
+	}
+
+	parts := strings.Split(envelope, ".")
+	if len(parts) != 3 || parts[0] != envelopeVersion {
+		return nil, errInvalidEnvelope
+	}
+	iv, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || len(iv) != envelopeIVSize {
+		return nil, errInvalidEnvelope
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(ciphertext) < envelopeTagSize {
+		return nil, errInvalidEnvelope
+	}
+	if len(ciphertext) > maxCiphertextSize {
+		return nil, errEnvelopeTooLarge
+	}
+
+	return append(iv, ciphertext...), nil
+}
+
+// formatEnvelope turns a stored IV and ciphertext back into an envelope for
+// the browser to decrypt.
+func formatEnvelope(b []byte) string {
+	if len(b) < envelopeIVSize {
+		return ""
+	}
+	return envelopeVersion + "." +
+		base64.RawURLEncoding.EncodeToString(b[:envelopeIVSize]) + "." +
+		base64.RawURLEncoding.EncodeToString(b[envelopeIVSize:])
+}
+
+// validateCiphertext checks the 'ciphertext' field of the create snippet
+// form, sent in place of the content when the snippet is encrypted, and
+// returns the IV and ciphertext in it. Only the main file can be encrypted,
+// so an encrypted snippet has just the one file, and no plain text content
+// alongside it.
+func validateCiphertext(form *forms.Form, files []*models.File) []byte {
+	if len(files) > 1 || form.Get("content") != "" {
+		form.Errors.Add("ciphertext", "An encrypted snippet can only have one file, with no plain text content")
+	}
+
+	// Readers of a burn after reading or password protected snippet see a
+	// form first, which loses the key in the link's fragment when it's
+	// submitted, so they would only ever get the ciphertext.
+	if form.Get("burn") != "" || form.Get("password") != "" {
+		form.Errors.Add("ciphertext", "An encrypted snippet can't be burnt after reading or have a password")
+	}
+
+	b, err := parseEnvelope(form.Get("ciphertext"))
+	switch err {
+	case nil:
+	case errEnvelopeTooLarge:
+		form.Errors.Add("ciphertext", fmt.Sprintf("This field is too long (maximum is %d bytes of ciphertext)", maxCiphertextSize))
+	default:
+		form.Errors.Add("ciphertext", "This field must be an encrypted envelope")
+	}
+	return b
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvelope(t *testing.T) {
+	iv := bytes.Repeat([]byte{1}, envelopeIVSize)
+	ciphertext := bytes.Repeat([]byte{2}, envelopeTagSize+4)
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	tests := []struct {
+		name     string
+		envelope string
+		want     []byte
+		wantErr  error
+	}{
+		{"Valid", "v1." + b64(iv) + "." + b64(ciphertext), append(iv, ciphertext...), nil},
+		{"Tag only", "v1." + b64(iv) + "." + b64(ciphertext[:envelopeTagSize]), append(iv, ciphertext[:envelopeTagSize]...), nil},
+		{"Empty", "", nil, errInvalidEnvelope},
+		{"Plain text", "An old silent pond...", nil, errInvalidEnvelope},
+		{"Unknown version", "v2." + b64(iv) + "." + b64(ciphertext), nil, errInvalidEnvelope},
+		{"Missing part", "v1." + b64(iv), nil, errInvalidEnvelope},
+		{"Extra part", "v1." + b64(iv) + "." + b64(ciphertext) + ".x", nil, errInvalidEnvelope},
+		{"Short IV", "v1." + b64(iv[:8]) + "." + b64(ciphertext), nil, errInvalidEnvelope},
+		{"No tag", "v1." + b64(iv) + "." + b64(ciphertext[:envelopeTagSize-1]), nil, errInvalidEnvelope},
+		{"Padded", "v1." + b64(iv) + "." + base64.URLEncoding.EncodeToString(ciphertext), nil, errInvalidEnvelope},
+		{"Standard alphabet", "v1." + b64(iv) + ".+/" + b64(ciphertext), nil, errInvalidEnvelope},
+		{"Largest", "v1." + b64(iv) + "." + b64(make([]byte, maxCiphertextSize)), append(iv, make([]byte, maxCiphertextSize)...), nil},
+		{"Too large", "v1." + b64(iv) + "." + b64(make([]byte, maxCiphertextSize+1)), nil, errEnvelopeTooLarge},
+		{"Far too large", strings.Repeat("a", 1<<20), nil, errEnvelopeTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := parseEnvelope(tt.envelope)
+			if err != tt.wantErr {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+			if !bytes.Equal(b, tt.want) {
+				t.Errorf("want %x; got %x", tt.want, b)
+			}
+		})
+	}
+}
+
+func TestFormatEnvelope(t *testing.T) {
+	envelope := "v1.AQEBAQEBAQEBAQEB.AgICAgICAgICAgICAgICAgICAgIC"
+
+	b, err := parseEnvelope(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatEnvelope(b); got != envelope {
+		t.Errorf("want %q; got %q", envelope, got)
+	}
+
+	if got := formatEnvelope([]byte{1, 2, 3}); got != "" {
+		t.Errorf("want empty envelope; got %q", got)
+	}
+}
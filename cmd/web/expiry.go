@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/forms"
+)
+
+// expiryPresets maps the preset values of the 'expires' form field to the
+// amount and unit of time they stand for. The 'never' and 'custom' values
+// are handled separately.
+var expiryPresets = map[string]struct {
+	amount int
+	unit   string
+}{
+	"10m": {10, "minutes"},
+	"1h":  {1, "hours"},
+	"1d":  {1, "days"},
+	"1w":  {1, "weeks"},
+	"1M":  {1, "months"},
+	"1y":  {1, "years"},
+}
+
+// expiryUnits lists the units a custom expiry can be given in.
+var expiryUnits = []string{"minutes", "hours", "days", "weeks", "months", "years"}
+
+// validateExpiry checks the 'expires' field of a form, along with the
+// 'expires_amount' and 'expires_unit' fields when a custom expiry is chosen.
+func validateExpiry(form *forms.Form) {
+	form.Required("expires")
+	values := []string{"never", "custom"}
+	for value := range expiryPresets {
+		values = append(values, value)
+	}
+	form.PermittedValues("expires", values...)
+
+	if form.Get("expires") == "custom" {
+		form.Required("expires_amount", "expires_unit")
+		form.IntBetween("expires_amount", 1, 999)
+		form.PermittedValues("expires_unit", expiryUnits...)
+	}
+}
+
+// expiryTime returns when a snippet should expire, given a form which has
+// passed validateExpiry and the current time. It returns the zero time if
+// the snippet should never expire.
+func expiryTime(form *forms.Form, now time.Time) time.Time {
+	switch value := form.Get("expires"); value {
+	case "never":
+		return time.Time{}
+	case "custom":
+		amount, _ := strconv.Atoi(form.Get("expires_amount"))
+		return addTime(now, amount, form.Get("expires_unit"))
+	default:
+		preset := expiryPresets[value]
+		return addTime(now, preset.amount, preset.unit)
+	}
+}
+
+// addTime adds amount of the given unit to t. Days and longer are added to
+// the calendar date, so that a month from the 31st January is the 2nd or 3rd
+// of March, as with time.AddDate.
+func addTime(t time.Time, amount int, unit string) time.Time {
+	switch unit {
+	case "minutes":
+		return t.Add(time.Duration(amount) * time.Minute)
+	case "hours":
+		return t.Add(time.Duration(amount) * time.Hour)
+	case "weeks":
+		return t.AddDate(0, 0, 7*amount)
+	case "months":
+		return t.AddDate(0, amount, 0)
+	case "years":
+		return t.AddDate(amount, 0, 0)
+	default:
+		return t.AddDate(0, 0, amount)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/forms"
+)
+
+func TestExpiryTime(t *testing.T) {
+	now := time.Date(2020, 1, 31, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		values url.Values
+		want   time.Time
+		valid  bool
+	}{
+		{
+			name:   "Ten minutes",
+			values: url.Values{"expires": {"10m"}},
+			want:   time.Date(2020, 1, 31, 10, 10, 0, 0, time.UTC),
+			valid:  true,
+		},
+		{
+			name:   "One month",
+			values: url.Values{"expires": {"1M"}},
+			want:   time.Date(2020, 3, 2, 10, 0, 0, 0, time.UTC),
+			valid:  true,
+		},
+		{
+			name:   "Never",
+			values: url.Values{"expires": {"never"}},
+			want:   time.Time{},
+			valid:  true,
+		},
+		{
+			name:   "Custom",
+			values: url.Values{"expires": {"custom"}, "expires_amount": {"3"}, "expires_unit": {"hours"}},
+			want:   time.Date(2020, 1, 31, 13, 0, 0, 0, time.UTC),
+			valid:  true,
+		},
+		{
+			name:   "Custom without amount",
+			values: url.Values{"expires": {"custom"}, "expires_unit": {"hours"}},
+			valid:  false,
+		},
+		{
+			name:   "Custom with bad unit",
+			values: url.Values{"expires": {"custom"}, "expires_amount": {"3"}, "expires_unit": {"fortnights"}},
+			valid:  false,
+		},
+		{
+			name:   "Unknown preset",
+			values: url.Values{"expires": {"365"}},
+			valid:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := forms.New(tt.values)
+			validateExpiry(form)
+
+			if form.Valid() != tt.valid {
+				t.Fatalf("want valid %v; got errors %v", tt.valid, form.Errors)
+			}
+			if !tt.valid {
+				return
+			}
+
+			if got := expiryTime(form, now); !got.Equal(tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
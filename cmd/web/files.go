@@ -0,0 +1,176 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cedrickchee/snippetbox/pkg/forms"
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// maxFiles is the most files a snippet can have, counting the main one.
+const maxFiles = 10
+
+// maxContentLength is the most characters a snippet file's content can have.
+// It applies to each file, so that a snippet which could be created can also
+// be edited.
+const maxContentLength = 4096
+
+// snippetFormSize is the most bytes of a snippet form's body allowed for each
+// file on it. That's enough for content of maxContentLength characters even
+// if every one is a four byte character, percent-encoded, plus the other
+// fields.
+const snippetFormSize = 12*maxContentLength + 4096
+
+// snippetFiles returns every file of a snippet, main file first. The main
+// file is named by snippetFilename if it wasn't given a name of its own. The
+// content of an encrypted snippet is its envelope.
+func snippetFiles(s *models.Snippet) []*models.File {
+	content := s.Content
+	if s.Encrypted {
+		content = formatEnvelope(s.Ciphertext)
+	}
+	files := []*models.File{{
+		Name:     snippetFilename(s),
+		Language: s.Language,
+		Content:  content,
+	}}
+	return append(files, s.Files...)
+}
+
+// hasMarkdown reports whether any of a snippet's files are Markdown, and so
+// are rendered rather than shown as source by default.
+func hasMarkdown(s *models.Snippet) bool {
+	for _, f := range snippetFiles(s) {
+		if f.Language == "markdown" {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultFilename returns the name given to a file on the create form which
+// was left without one, from its position and language. The main file is
+// never given a default name, so n is always at least 2.
+func defaultFilename(n int, language string) string {
+	return fmt.Sprintf("file%d%s", n, languageExt(language))
+}
+
+// fileField returns the name under which errors for a field of the i'th file
+// on the create form are reported. The main file's fields are the same as on
+// the edit form, so its errors are reported under the plain field name.
+func fileField(field string, i int) string {
+	if i == 0 {
+		return field
+	}
+	return fmt.Sprintf("%s.%d", field, i)
+}
+
+// formFiles returns the files entered on the create snippet form. Each file
+// has a 'filename', 'language' and 'content' field, repeated in order, with
+// the main file first. There's always at least one file.
+func formFiles(form *forms.Form) []*models.File {
+	names, languages, contents := form.Values["filename"], form.Values["language"], form.Values["content"]
+
+	n := len(contents)
+	if n == 0 {
+		n = 1
+	}
+
+	files := make([]*models.File, n)
+	for i := range files {
+		files[i] = &models.File{
+			Name:     strings.TrimSpace(valueAt(names, i)),
+			Language: valueAt(languages, i),
+			Content:  valueAt(contents, i),
+		}
+	}
+	return files
+}
+
+// valueAt returns the i'th of a field's values, or the empty string if there
+// aren't that many.
+func valueAt(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+// setFormFiles replaces the files on the create snippet form.
+func setFormFiles(form *forms.Form, files []*models.File) {
+	names := make([]string, len(files))
+	languages := make([]string, len(files))
+	contents := make([]string, len(files))
+	for i, f := range files {
+		names[i], languages[i], contents[i] = f.Name, f.Language, f.Content
+	}
+	form.Values["filename"] = names
+	form.Values["language"] = languages
+	form.Values["content"] = contents
+}
+
+// validateFiles checks the files on the create snippet form. The main file's
+// content and language are already checked by validateSnippetForm, so only
+// the further files' are checked here. Every file's name must be valid, and
+// different from the others, since they end up side by side in a zip.
+func validateFiles(form *forms.Form, files []*models.File) {
+	if len(files) > maxFiles {
+		form.Errors.Add("files", fmt.Sprintf("A snippet can have at most %d files", maxFiles))
+	}
+
+	seen := map[string]bool{}
+	for i, f := range files {
+		name := f.Name
+		switch {
+		case name != "" && !forms.FilenameRX.MatchString(name):
+			form.Errors.Add(fileField("filename", i), "This field is invalid")
+		case name == "" && i == 0:
+			name = snippetFilename(&models.Snippet{Title: form.Get("title"), Language: f.Language})
+		case name == "":
+			name = defaultFilename(i+1, f.Language)
+		}
+
+		// Names are compared regardless of case, as not every system that
+		// might unpack the zip can tell 'Makefile' from 'makefile'.
+		key := strings.ToLower(name)
+		if seen[key] {
+			form.Errors.Add(fileField("filename", i), "Another file already has this name")
+		}
+		seen[key] = true
+
+		if i == 0 {
+			continue
+		}
+		if strings.TrimSpace(f.Content) == "" {
+			form.Errors.Add(fileField("content", i), "This field cannot be blank")
+		} else if utf8.RuneCountInString(f.Content) > maxContentLength {
+			form.Errors.Add(fileField("content", i), fmt.Sprintf("This field is too long (maximum is %d characters)", maxContentLength))
+		}
+		if f.Language != "" && languageLabel(f.Language) == "" {
+			form.Errors.Add(fileField("language", i), "This field is invalid")
+		}
+	}
+}
+
+// writeZip writes every file of a snippet to w as a zip archive.
+func writeZip(w io.Writer, s *models.Snippet) error {
+	zw := zip.NewWriter(w)
+	for _, f := range snippetFiles(s) {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: s.Created,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.Content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/cedrickchee/snippetbox/pkg/diff"
 	"github.com/cedrickchee/snippetbox/pkg/forms"
 	"github.com/cedrickchee/snippetbox/pkg/models"
 )
@@ -33,105 +42,1876 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
 // showSnippet is a handler function.
 func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
-	// Extract the value of the id parameter from the query string and try to
-	// convert it to an integer using the strconv.Atoi() function. If it can't
-	// be converted to an integer, or the value is less than 1, we return a 404 page
-	// not found response.
-	//
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+
+	// A password protected snippet stays behind the unlock form until the
+	// right password has been given.
+	if !app.isUnlocked(r, s) {
+		app.render(w, r, "unlock.page.tmpl", &templateData{Snippet: s, Form: forms.New(nil)})
+		return
+	}
+
+	// Viewing a burn-after-reading snippet deletes it, so a GET request only
+	// gets a page asking for confirmation. That way link previews and
+	// crawlers, which only ever make GET requests, can't burn it.
+	if s.BurnAfterReading {
+		app.render(w, r, "burn.page.tmpl", &templateData{Snippet: s})
+		return
+	}
+
+	app.countView(r, s)
+	app.renderSnippet(w, r, s, forms.New(nil))
+}
+
+// maxViewed is the most snippets remembered as viewed in a session. A
+// snippet which drops off the end is counted again if it's viewed again.
+const maxViewed = 50
+
+// countView counts a view of a snippet, unless it's by a bot, by the owner,
+// or by somebody who has already viewed it in this session.
+func (app *application) countView(r *http.Request, s *models.Snippet) {
+	if isBot(r) || app.isOwner(r, s) {
+		return
+	}
+
+	viewed := strings.Fields(app.session.GetString(r, "viewed"))
+	for _, shortID := range viewed {
+		if shortID == s.ShortID {
+			return
+		}
+	}
+	viewed = append(viewed, s.ShortID)
+	if len(viewed) > maxViewed {
+		viewed = viewed[len(viewed)-maxViewed:]
+	}
+	app.session.Put(r, "viewed", strings.Join(viewed, " "))
+
+	app.viewCounter.record(s.ID, referrerDomain(r))
+}
+
+// showStats shows the owner of a snippet how many times it has been viewed
+// each day recently, and which sites the visitors came from.
+func (app *application) showStats(w http.ResponseWriter, r *http.Request) {
+	s := app.ownedSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-statsDays)
+
+	daily, err := app.views.Daily(s.ID, since)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	referrers, err := app.views.Referrers(s.ID, since, 10)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	total, err := app.views.Total(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "stats.page.tmpl", &templateData{
+		Snippet: s,
+		Stats:   newViewStats(daily, referrers, total, since, today),
+	})
+}
+
+// renderSnippet renders the page for a snippet, with its comments and the
+// given form for adding a new comment.
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, s *models.Snippet, form *forms.Form) {
+	// Signed in users get a button to star or unstar the snippet, depending
+	// on whether they've already starred it.
+	var starred bool
+	if user := app.authenticatedUser(r); user != nil {
+		var err error
+		starred, err = app.snippets.Starred(user.ID, s.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	// They also get the forms to add it to and take it out of their
+	// collections.
+	var collections []*models.Collection
+	if user := app.authenticatedUser(r); user != nil {
+		var err error
+		collections, err = app.collections.ForSnippet(user.ID, s.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	comments, err := app.comments.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Create an instance of a templateData struct holding the snippet data.
+	// Then, use the new render helper. Markdown snippets are rendered unless
+	// the source was asked for with '?view=source'.
+	app.render(w, r, "show.page.tmpl", &templateData{
+		Snippet:     s,
+		ShowSource:  r.URL.Query().Get("view") == "source",
+		Starred:     starred,
+		Collections: collections,
+		Comments:    comments,
+		Form:        form,
+	})
+}
+
+// burnSnippet shows a burn-after-reading snippet, deleting it in the
+// process.
+func (app *application) burnSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+
+	if !app.isUnlocked(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	// Burn hands the snippet to exactly one caller, so if somebody else got
+	// here first this is a 404 like any other missing snippet.
+	s, err := app.snippets.Burn(s.ShortID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The page can't be fetched again, so make sure it isn't kept around in
+	// any caches either.
+	w.Header().Set("Cache-Control", "no-store")
+	app.render(w, r, "show.page.tmpl", &templateData{
+		Snippet: s,
+	})
+}
+
+// readableSnippet is like snippetFromURL, but for pages other than the
+// snippet page itself which reveal the snippet's content. The content of a
+// burn-after-reading snippet may only be revealed by burning it, so to
+// anyone but the owner those pages are not found. Those pages are forbidden
+// for a password protected snippet until it has been unlocked.
+func (app *application) readableSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return nil
+	}
+
+	if s.BurnAfterReading && !app.isOwner(r, s) {
+		app.notFound(w)
+		return nil
+	}
+
+	if !app.isUnlocked(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return nil
+	}
+
+	return s
+}
+
+// maxUnlocked is the most snippets remembered as unlocked in a session. The
+// session is kept in a cookie, so the list can't grow forever; the snippet
+// unlocked longest ago is forgotten first.
+const maxUnlocked = 20
+
+// isUnlocked reports whether the current user can view a snippet without
+// giving a password. That's always true for a snippet without one, and for
+// a password protected snippet if it's theirs or they have already given its
+// password in this session.
+func (app *application) isUnlocked(r *http.Request, s *models.Snippet) bool {
+	if !s.Protected || app.isOwner(r, s) {
+		return true
+	}
+	for _, shortID := range strings.Fields(app.session.GetString(r, "unlocked")) {
+		if shortID == s.ShortID {
+			return true
+		}
+	}
+	return false
+}
+
+// The number of wrong passwords a client can give for a snippet within the
+// window before the unlock form stops accepting any more.
+const (
+	maxUnlockFailures = 5
+	unlockWindow      = 15 * time.Minute
+)
+
+// unlockSnippet checks the password given on the unlock form for a password
+// protected snippet, and if it's right remembers the snippet as unlocked for
+// the rest of the session.
+func (app *application) unlockSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return
+	}
+
+	if app.isUnlocked(r, s) {
+		http.Redirect(w, r, "/snippet/"+s.ShortID, http.StatusSeeOther)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Attempts are counted for each client and snippet, so that nobody can
+	// keep guessing at a snippet's password. Each one is counted before the
+	// password is checked, since checking takes long enough for a client to
+	// send plenty more guesses alongside it.
+	key := clientIP(r) + " " + s.ShortID
+	if !app.unlockLimiter.attempt(key) {
+		app.clientError(w, http.StatusTooManyRequests)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	err = app.snippets.Unlock(s.ID, form.Get("password"))
+	if err == models.ErrInvalidCredentials {
+		form.Errors.Add("generic", "Password is incorrect")
+		app.render(w, r, "unlock.page.tmpl", &templateData{Snippet: s, Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.unlockLimiter.succeed(key)
+
+	unlocked := append(strings.Fields(app.session.GetString(r, "unlocked")), s.ShortID)
+	if len(unlocked) > maxUnlocked {
+		unlocked = unlocked[len(unlocked)-maxUnlocked:]
+	}
+	app.session.Put(r, "unlocked", strings.Join(unlocked, " "))
+
+	http.Redirect(w, r, "/snippet/"+s.ShortID, http.StatusSeeOther)
+}
+
+// clientIP returns the IP address the request came from.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// snippetFromURL fetches the snippet named by the ':id' URL parameter. If
+// there is no such snippet the appropriate error response has already been
+// sent and nil is returned.
+func (app *application) snippetFromURL(w http.ResponseWriter, r *http.Request) *models.Snippet {
 	// Pat doesn't strip the colon from the named capture key, so we need to
 	// get the value of ':id' from the query string instead of 'id'.
+	shortID := r.URL.Query().Get(":id")
+
+	// Snippets used to be addressed by their sequential integer ID. Those
+	// URLs are still around, so send them on to the short ID equivalent. Only
+	// an ID written the way we wrote them is redirected: the redirect is made
+	// by swapping it for the short ID in the path, so anything like '01' or
+	// '+1' would be redirected straight back to itself.
+	if id, err := strconv.Atoi(shortID); err == nil && strconv.Itoa(id) == shortID {
+		app.redirectLegacySnippet(w, r, id)
+		return nil
+	}
+
+	// There's no point asking the database about something which can't be a
+	// short ID, so we return a 404 page not found response straight away.
+	if !models.IsShortID(shortID) {
+		app.notFound(w) // Use the notFound() helper.
+		return nil
+	}
+
+	// Use the SnippetModel object's Get method to retrieve the data for a
+	// specific record based on its short ID. If no matching record is found,
+	// return a 404 Not Found response.
+	s, err := app.snippets.Get(shortID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return nil
+	} else if err != nil {
+		app.serverError(w, err)
+		return nil
+	}
+
+	// Private snippets can only be seen by their owner. To everybody else we
+	// respond exactly as if the snippet didn't exist, so that its existence
+	// isn't leaked.
+	if !app.canView(r, s) {
+		app.notFound(w)
+		return nil
+	}
+
+	return s
+}
+
+// redirectLegacySnippet permanently redirects a GET request for a URL using a
+// snippet's integer ID to the same URL using its short ID.
+//
+// Integer IDs are trivial to enumerate, so only public snippets (which are
+// listed on the home page anyway) are redirected for everyone. Unlisted and
+// private snippets are only redirected for their owner; to anybody else their
+// old URLs are not found, as otherwise the redirect would give away the
+// short ID.
+func (app *application) redirectLegacySnippet(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodGet || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	s, err := app.snippets.GetByID(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+	if s.Visibility != models.VisibilityPublic && (user == nil || user.ID != s.UserID) {
+		app.notFound(w)
+		return
+	}
+
+	// Pat adds its named captures to the query string, so take those back
+	// out before handing on the rest of the query.
+	query := r.URL.Query()
+	for key := range query {
+		if strings.HasPrefix(key, ":") {
+			query.Del(key)
+		}
+	}
+
+	u := url.URL{
+		Path:     strings.Replace(r.URL.Path, fmt.Sprintf("/snippet/%d", id), "/snippet/"+s.ShortID, 1),
+		RawQuery: query.Encode(),
+	}
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+}
+
+// canView reports whether the current user is allowed to view the snippet.
+func (app *application) canView(r *http.Request, s *models.Snippet) bool {
+	return s.Visibility != models.VisibilityPrivate || app.isOwner(r, s)
+}
+
+// isOwner reports whether the current user owns the snippet.
+func (app *application) isOwner(r *http.Request, s *models.Snippet) bool {
+	user := app.authenticatedUser(r)
+	return user != nil && user.ID == s.UserID
+}
+
+func (app *application) showHistory(w http.ResponseWriter, r *http.Request) {
+	s := app.readableSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "history.page.tmpl", &templateData{
+		Snippet:   s,
+		Revisions: revisions,
+	})
+}
+
+func (app *application) showDiff(w http.ResponseWriter, r *http.Request) {
+	s := app.readableSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	// The revisions to compare are given by their IDs in the 'from' and 'to'
+	// query string parameters.
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil || from < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil || to < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Revision only returns revisions of the given snippet, so a revision ID
+	// belonging to some other snippet is treated as not found.
+	fromRevision, err := app.snippets.Revision(s.ID, from)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	toRevision, err := app.snippets.Revision(s.ID, to)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "diff.page.tmpl", &templateData{
+		Snippet:      s,
+		FromRevision: fromRevision,
+		ToRevision:   toRevision,
+		Diff:         diff.Unified(fromRevision.Content, toRevision.Content, 3),
+	})
+}
+
+// listSnippets lists all the public snippets, newest first, a page at a
+// time.
+func (app *application) listSnippets(w http.ResponseWriter, r *http.Request) {
+	td := app.snippetPage(w, r, app.snippets.List)
+	if td == nil {
+		return
+	}
+
+	td.PagePath = "/snippets"
+	app.render(w, r, "snippets.page.tmpl", td)
+}
+
+// showUser shows the profile of the user with the ID given by the ':id' URL
+// parameter, along with their public snippets, newest first, a page at a
+// time.
+func (app *application) showUser(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	user, err := app.users.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td := app.snippetPage(w, r, func(cursor *models.Cursor, newer bool, limit int) ([]*models.Snippet, error) {
+		return app.snippets.ByUser(user.ID, false, cursor, newer, limit)
+	})
+	if td == nil {
+		return
+	}
+
+	// Their public collections are listed too.
+	td.Collections, err = app.collections.ByUser(user.ID, false)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td.User = user
+	td.PagePath = fmt.Sprintf("/user/%d", user.ID)
+	app.render(w, r, "user.page.tmpl", td)
+}
+
+// mySnippets lists all of the current user's snippets, whatever their
+// visibility, newest first, a page at a time.
+func (app *application) mySnippets(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	td := app.snippetPage(w, r, func(cursor *models.Cursor, newer bool, limit int) ([]*models.Snippet, error) {
+		return app.snippets.ByUser(user.ID, true, cursor, newer, limit)
+	})
+	if td == nil {
+		return
+	}
+
+	td.PagePath = "/snippets/mine"
+	app.render(w, r, "mine.page.tmpl", td)
+}
+
+// myStars lists the snippets the current user has starred, newest first, a
+// page at a time.
+func (app *application) myStars(w http.ResponseWriter, r *http.Request) {
+	user := app.authenticatedUser(r)
+
+	td := app.snippetPage(w, r, func(cursor *models.Cursor, newer bool, limit int) ([]*models.Snippet, error) {
+		return app.snippets.StarredBy(user.ID, cursor, newer, limit)
+	})
+	if td == nil {
+		return
+	}
+
+	td.PagePath = "/snippets/starred"
+	app.render(w, r, "starred.page.tmpl", td)
+}
+
+// popularSize is the number of snippets listed on the popular page.
+const popularSize = 20
+
+// popularSince returns the start of the named time window for the popular
+// page, counting back from now. The zero time is returned for "all", and
+// false for a window we don't know.
+func popularSince(window string, now time.Time) (time.Time, bool) {
+	switch window {
+	case "day":
+		return now.AddDate(0, 0, -1), true
+	case "week":
+		return now.AddDate(0, 0, -7), true
+	case "month":
+		return now.AddDate(0, -1, 0), true
+	case "year":
+		return now.AddDate(-1, 0, 0), true
+	case "all":
+		return time.Time{}, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// popular lists the public snippets with the most stars given within the
+// time window named by the 'window' query string parameter, which defaults
+// to the past week.
+func (app *application) popular(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "week"
+	}
+
+	since, ok := popularSince(window, time.Now())
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, err := app.snippets.Popular(since, popularSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "popular.page.tmpl", &templateData{Snippets: snippets, Window: window})
+}
+
+// The number of snippets listed on each page of a snippet listing, unless
+// another page size between 1 and maxPageSize is asked for.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// snippetPage fetches the page of a snippet listing asked for by the request,
+// using the given store method, and returns the template data for it. The
+// page size is given by the 'limit' query string parameter. The pages are
+// linked by cursors: 'before' gives the snippets older than a snippet, and
+// 'after' the ones newer than it. If the request is invalid, the appropriate
+// error response has already been sent and nil is returned.
+func (app *application) snippetPage(w http.ResponseWriter, r *http.Request, fetch func(*models.Cursor, bool, int) ([]*models.Snippet, error)) *templateData {
+	query := r.URL.Query()
+
+	limit := defaultPageSize
+	if l := query.Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			app.clientError(w, http.StatusBadRequest)
+			return nil
+		}
+	}
+
+	var cursor *models.Cursor
+	var err error
+	before, after := query.Get("before"), query.Get("after")
+	newer := after != ""
+	switch {
+	case before != "" && after != "":
+		err = errInvalidCursor
+	case before != "":
+		cursor, err = decodeCursor(before)
+	case after != "":
+		cursor, err = decodeCursor(after)
+	}
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return nil
+	}
+
+	// Ask for one snippet more than fits on the page, so we know whether
+	// there is another page further on in the direction we're going.
+	snippets, err := fetch(cursor, newer, limit+1)
+	if err != nil {
+		app.serverError(w, err)
+		return nil
+	}
+	more := len(snippets) > limit
+	if more {
+		if newer {
+			snippets = snippets[1:]
+		} else {
+			snippets = snippets[:limit]
+		}
+	}
+
+	// Coming from a cursor means there is a page back the way we came.
+	td := &templateData{Snippets: snippets, PageSize: limit}
+	if len(snippets) > 0 {
+		if (more && !newer) || (cursor != nil && newer) {
+			td.NextCursor = encodeCursor(snippets[len(snippets)-1])
+		}
+		if (more && newer) || (cursor != nil && !newer) {
+			td.PrevCursor = encodeCursor(snippets[0])
+		}
+	}
+
+	return td
+}
+
+// tagPageSize is the number of snippets listed on each page of a tag.
+const tagPageSize = 10
+
+// showTag lists the public snippets with the tag named by the ':name' URL
+// parameter, a page at a time. The page is given by the 'page' query string
+// parameter, and defaults to the first.
+func (app *application) showTag(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(r.URL.Query().Get(":name"))
+	if !forms.TagRX.MatchString(tag) {
+		app.notFound(w)
+		return
+	}
+
+	page, ok := pageNumber(r)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if page > maxPage {
+		app.notFound(w)
+		return
+	}
+
+	// Ask for one snippet more than fits on the page, so we know whether
+	// there is a next page without having to count them all.
+	snippets, err := app.snippets.Tagged(tag, tagPageSize+1, (page-1)*tagPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td := &templateData{Tag: tag, Snippets: snippets}
+	if len(snippets) > tagPageSize {
+		td.Snippets = snippets[:tagPageSize]
+		td.NextPage = page + 1
+	}
+	if page > 1 {
+		td.PrevPage = page - 1
+	}
+
+	app.render(w, r, "tag.page.tmpl", td)
+}
+
+// maxQueryLength is the longest search query we accept, in characters.
+const maxQueryLength = 100
+
+// showSearch lists the public snippets matching the 'q' query string
+// parameter, a page at a time. Without a query it just shows the search form.
+func (app *application) showSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if utf8.RuneCountInString(query) > maxQueryLength {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	page, ok := pageNumber(r)
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	if page > maxPage {
+		app.notFound(w)
+		return
+	}
+
+	td := &templateData{Query: query}
+	if query != "" {
+		snippets, more, err := app.snippets.Search(query, page)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		td.Snippets = snippets
+		if more {
+			td.NextPage = page + 1
+		}
+		if page > 1 {
+			td.PrevPage = page - 1
+		}
+	}
+
+	app.render(w, r, "search.page.tmpl", td)
+}
+
+// maxPage is the highest page number of a paginated listing which is shown.
+// Anything beyond it is not found, rather than risk the offset of the page
+// overflowing.
+const maxPage = 1000
+
+// pageNumber returns the page number given by the 'page' query string
+// parameter, defaulting to the first page. It returns false if the parameter
+// isn't a valid page number.
+func pageNumber(r *http.Request) (int, bool) {
+	p := r.URL.Query().Get("page")
+	if p == "" {
+		return 1, true
+	}
+
+	page, err := strconv.Atoi(p)
+	if err != nil || page < 1 {
+		return 0, false
+	}
+	return page, true
+}
+
+// rawSnippet writes out the content of a snippet exactly as it was saved,
+// so that it can be fetched by scripts and command line tools.
+func (app *application) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.readableSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	app.writeContent(w, s)
+}
+
+// downloadSnippet is like rawSnippet, but asks the browser to save the
+// content as a file rather than show it.
+func (app *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.readableSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{
+		"filename": snippetFilename(s),
+	})
+	w.Header().Set("Content-Disposition", disposition)
+	app.writeContent(w, s)
+}
+
+// writeContent writes the content of a snippet's main file as plain text.
+// Browsers are told not to sniff the content type, so that a snippet
+// containing HTML is never rendered as a page on our origin.
+func (app *application) writeContent(w http.ResponseWriter, s *models.Snippet) {
+	content := snippetFiles(s)[0].Content
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	io.WriteString(w, content)
+}
+
+// snippetFilename returns the name a snippet's main file is saved under when
+// downloaded. That's the name it was given, if any. Otherwise it's made from
+// the snippet's slug and the extension for its language. What's saved of an
+// encrypted snippet is its envelope, so '.enc' is added to the name.
+func snippetFilename(s *models.Snippet) string {
+	name := s.Filename
+	if name == "" {
+		name = snippetSlug(s) + languageExt(s.Language)
+	}
+	if s.Encrypted {
+		name += ".enc"
+	}
+	return name
+}
+
+// snippetSlug returns a name for a snippet which is safe to use in a
+// filename. It is made from the snippet's title, falling back to its short
+// ID when the title has nothing usable in it.
+func snippetSlug(s *models.Snippet) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s.Title) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+		if b.Len() >= 50 {
+			break
+		}
+	}
+
+	if b.Len() == 0 {
+		return s.ShortID
+	}
+	return b.String()
+}
+
+// downloadZip sends every file of a snippet in a single zip archive.
+func (app *application) downloadZip(w http.ResponseWriter, r *http.Request) {
+	s := app.readableSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	// The archive is built in memory first, so that if anything goes wrong
+	// we can still send an error rather than half a zip.
+	buf := new(bytes.Buffer)
+	err := writeZip(buf, s)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{
+		"filename": snippetSlug(s) + ".zip",
+	})
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(w)
+}
+
+func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "create.page.tmpl", &templateData{
+		// Pass a new empty forms.Form object to the template, with a single
+		// empty file on it.
+		Form:  forms.New(nil),
+		Files: []*models.File{{}},
+	})
+}
+
+// createSnippet is a handler function.
+func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
+	// The check of r.Method != 'POST' is now superfluous and can be removed.
+
+	// if r.Method != "POST" {
+	// 	// Use the Header().Set() method to add an 'Allow: POST' header to the
+	// 	// response header map. The first parameter is the header name, and
+	// 	// the second parameter is the header value.
+	// 	w.Header().Set("Allow", "POST")
+
+	// 	// Use the http.Error() function to send a 405 status code and "Method Not
+	// 	// Allowed" string as the response body.
+	// 	app.clientError(w, http.StatusMethodNotAllowed) // Use the clientError() helper.
+	// 	return
+	// }
+
+	// Form size. Limit the request body size to snippetFormSize bytes for
+	// each file.
+	r.Body = http.MaxBytesReader(w, r.Body, snippetFormSize*maxFiles)
+
+	// First we call r.ParseForm() which adds any data in POST request bodies
+	// to the r.PostForm map. This also works in the same way for PUT and PATCH
+	// requests. If there are any errors, we use our app.ClientError helper to send
+	// a 400 Bad Request response to the user.
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Create a new forms.Form struct containing the POSTed data from the
+	// form, then use the validation methods to check the content.
+	form := forms.New(r.PostForm)
+	files := formFiles(form)
+
+	// The buttons to add and remove files submit the form too, but only to
+	// change the files on it, so it's shown again without being checked.
+	if form.Get("add") != "" || form.Get("remove") != "" {
+		if form.Get("add") != "" && len(files) < maxFiles {
+			files = append(files, &models.File{})
+		}
+		if i, err := strconv.Atoi(form.Get("remove")); err == nil && i >= 0 && i < len(files) && len(files) > 1 {
+			files = append(files[:i], files[i+1:]...)
+		}
+		setFormFiles(form, files)
+		app.render(w, r, "create.page.tmpl", &templateData{Form: form, Files: files})
+		return
+	}
+
+	validateSnippetForm(form)
+	validateFiles(form, files)
+	validateExpiry(form)
+	validatePassword(form)
+	form.PermittedValues("burn", "true")
+
+	// An encrypted snippet's content is sent as an envelope, in place of
+	// the main file's content. The server can't read it, so it has no
+	// language either.
+	var ciphertext []byte
+	if form.Get("ciphertext") != "" {
+		ciphertext = validateCiphertext(form, files)
+		files[0].Language = ""
+	}
+
+	// If the form isn't valid, redisplay the template passing in the
+	// form.Form object as the data.
+	if !form.Valid() {
+		app.render(w, r, "create.page.tmpl", &templateData{Form: form, Files: files})
+		return
+	}
+
+	// Files left without a name are named after their position, so that
+	// every file has a name to go in the zip under.
+	for i, f := range files[1:] {
+		if f.Name == "" {
+			f.Name = defaultFilename(i+2, f.Language)
+		}
+	}
+
+	// Create a new snippet record in the database using the form data by
+	// passing the data to the SnippetModel.Insert() method, receiving the
+	// short ID of the new record back. The snippet is owned by the current
+	// user; this route sits behind requireAuthenticatedUser so there always
+	// is one.
+	shortID, err := app.snippets.Insert(&models.Snippet{
+		UserID:           app.authenticatedUser(r).ID,
+		Title:            form.Get("title"),
+		Filename:         files[0].Name,
+		Content:          files[0].Content,
+		Language:         files[0].Language,
+		Files:            files[1:],
+		Ciphertext:       ciphertext,
+		Visibility:       form.Get("visibility"),
+		BurnAfterReading: form.Get("burn") == "true",
+		Password:         form.Get("password"),
+		Tags:             formTags(form),
+		Expires:          expiryTime(form, time.Now()),
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Use the Put() method to add a string value ('Your snippet was saved
+	// successfully!') and the corresponding key ('flash') to the session
+	// data. Note that if there's no existing session for the current user
+	// (or their session has expired) then a new, empty, session for them
+	// will automatically be created by the session middleware.
+	if form.Get("burn") == "true" {
+		app.session.Put(r, "flash", "Snippet successfully created. It will be deleted the first time it is viewed.")
+	} else {
+		app.session.Put(r, "flash", "Snippet successfully created")
+	}
+
+	// Redirect the user to the relevant page for the snippet.
+	http.Redirect(w, r, "/snippet/"+shortID, http.StatusSeeOther)
+}
+
+// forkSnippet creates a copy of a snippet owned by the current user, and
+// sends them on to edit it. The copy keeps the visibility and expiry time of
+// the original, so that forking never makes content more widely available or
+// longer lived than its owner intended.
+func (app *application) forkSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.readableSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	// The content of a burn-after-reading snippet is only ever shown once,
+	// so it can't be forked, not even by its owner. Nor can an encrypted
+	// snippet, as a fork is made to be edited, and the server can't read
+	// it to edit.
+	if s.BurnAfterReading || s.Encrypted {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Only a hash of a snippet's password is kept, so it can't be copied.
+	// A fork of a password protected snippet is made private instead.
+	visibility := s.Visibility
+	if s.Protected {
+		visibility = models.VisibilityPrivate
+	}
+
+	shortID, err := app.snippets.Insert(&models.Snippet{
+		UserID:     app.authenticatedUser(r).ID,
+		Title:      s.Title,
+		Filename:   s.Filename,
+		Content:    s.Content,
+		Language:   s.Language,
+		Files:      s.Files,
+		Visibility: visibility,
+		Tags:       s.Tags,
+		Expires:    s.Expires,
+		ParentID:   s.ID,
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet successfully forked. Make your changes below.")
+
+	http.Redirect(w, r, "/snippet/"+shortID+"/edit", http.StatusSeeOther)
+}
+
+// starSnippet stars a snippet for the current user.
+func (app *application) starSnippet(w http.ResponseWriter, r *http.Request) {
+	app.setStar(w, r, true)
+}
+
+// unstarSnippet takes away the current user's star from a snippet.
+func (app *application) unstarSnippet(w http.ResponseWriter, r *http.Request) {
+	app.setStar(w, r, false)
+}
+
+// setStar stars or unstars a snippet for the current user, and sends them
+// back to the snippet. Either way it's safe to repeat: starring a snippet
+// twice leaves it with one star from the user, and so on.
+func (app *application) setStar(w http.ResponseWriter, r *http.Request, star bool) {
+	s := app.readableSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	// The content of a burn-after-reading snippet is gone once it has been
+	// read, so there's nothing left worth starring.
+	if s.BurnAfterReading {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	userID := app.authenticatedUser(r).ID
+	if star {
+		err = app.snippets.Star(userID, s.ID)
+	} else {
+		err = app.snippets.Unstar(userID, s.ID)
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/snippet/"+s.ShortID, http.StatusSeeOther)
+}
+
+// collectSnippet adds a snippet to one of the current user's collections.
+func (app *application) collectSnippet(w http.ResponseWriter, r *http.Request) {
+	app.setCollected(w, r, true)
+}
+
+// uncollectSnippet takes a snippet out of one of the current user's
+// collections.
+func (app *application) uncollectSnippet(w http.ResponseWriter, r *http.Request) {
+	app.setCollected(w, r, false)
+}
+
+// setCollected adds a snippet to, or takes it out of, the collection given
+// by the 'collection' form field, and sends the user back to the snippet.
+// Like starring, it's safe to repeat.
+func (app *application) setCollected(w http.ResponseWriter, r *http.Request, add bool) {
+	s := app.readableSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	// A burn-after-reading snippet is gone once it has been read, so it
+	// can't be kept in a collection.
+	if add && s.BurnAfterReading {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// The collection comes from a select box or a hidden field, so if it's
+	// missing or belongs to somebody else the form has been tampered with.
+	shortID := r.PostFormValue("collection")
+	if !models.IsShortID(shortID) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	c, err := app.collections.Get(shortID)
+	if err == models.ErrNoRecord {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if c.UserID != app.authenticatedUser(r).ID {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	if add {
+		err = app.collections.Add(c.ID, s.ID)
+	} else {
+		err = app.collections.Remove(c.ID, s.ShortID)
+	}
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if add {
+		app.session.Put(r, "flash", fmt.Sprintf("Snippet added to %s", c.Name))
+	} else {
+		app.session.Put(r, "flash", fmt.Sprintf("Snippet removed from %s", c.Name))
+	}
+
+	http.Redirect(w, r, "/snippet/"+s.ShortID, http.StatusSeeOther)
+}
+
+// collectionFromURL fetches the collection named by the ':id' URL parameter.
+// Like private snippets, private collections are not found by anyone but
+// their owner. If there is no such collection the appropriate error response
+// has already been sent and nil is returned.
+func (app *application) collectionFromURL(w http.ResponseWriter, r *http.Request) *models.Collection {
+	shortID := r.URL.Query().Get(":id")
+	if !models.IsShortID(shortID) {
+		app.notFound(w)
+		return nil
+	}
+
+	c, err := app.collections.Get(shortID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return nil
+	} else if err != nil {
+		app.serverError(w, err)
+		return nil
+	}
+
+	user := app.authenticatedUser(r)
+	if c.Visibility == models.VisibilityPrivate && (user == nil || user.ID != c.UserID) {
+		app.notFound(w)
+		return nil
+	}
+
+	return c
+}
+
+// ownedCollection is like collectionFromURL, but also checks that the
+// collection belongs to the authenticated user.
+func (app *application) ownedCollection(w http.ResponseWriter, r *http.Request) *models.Collection {
+	c := app.collectionFromURL(w, r)
+	if c == nil {
+		return nil
+	}
+
+	if c.UserID != app.authenticatedUser(r).ID {
+		app.clientError(w, http.StatusForbidden)
+		return nil
+	}
+
+	return c
+}
+
+// validateCollectionForm runs the checks shared by the create and edit
+// collection forms.
+func validateCollectionForm(form *forms.Form) {
+	form.Required("name", "visibility")
+	form.MaxLength("name", 100)
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate)
+}
+
+// myCollections lists all of the current user's collections, with a form
+// for starting a new one.
+func (app *application) myCollections(w http.ResponseWriter, r *http.Request) {
+	app.renderCollections(w, r, forms.New(nil))
+}
+
+// renderCollections renders the page listing the current user's
+// collections, with the given form for creating a new one.
+func (app *application) renderCollections(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	collections, err := app.collections.ByUser(app.authenticatedUser(r).ID, true)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "collections.page.tmpl", &templateData{
+		Collections: collections,
+		Form:        form,
+	})
+}
+
+func (app *application) createCollection(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	validateCollectionForm(form)
+
+	if !form.Valid() {
+		app.renderCollections(w, r, form)
+		return
+	}
+
+	shortID, err := app.collections.Insert(app.authenticatedUser(r).ID, form.Get("name"), form.Get("visibility"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Collection successfully created")
+
+	http.Redirect(w, r, "/collection/"+shortID, http.StatusSeeOther)
+}
+
+// showCollection shows the snippets in a collection, in order. The owner
+// also gets the forms for changing it.
+func (app *application) showCollection(w http.ResponseWriter, r *http.Request) {
+	c := app.collectionFromURL(w, r)
+	if c == nil {
+		return
+	}
+
+	form := forms.New(url.Values{})
+	form.Set("name", c.Name)
+	form.Set("visibility", c.Visibility)
+
+	app.renderCollection(w, r, c, form)
+}
+
+// renderCollection renders the page for a collection, with the given form
+// for editing it. Only the snippets the current user can view are shown.
+func (app *application) renderCollection(w http.ResponseWriter, r *http.Request, c *models.Collection, form *forms.Form) {
+	userID := 0
+	if user := app.authenticatedUser(r); user != nil {
+		userID = user.ID
+	}
+
+	snippets, err := app.snippets.InCollection(c.ID, userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "collection.page.tmpl", &templateData{
+		Collection: c,
+		Snippets:   snippets,
+		Form:       form,
+	})
+}
+
+func (app *application) editCollection(w http.ResponseWriter, r *http.Request) {
+	c := app.ownedCollection(w, r)
+	if c == nil {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 4096)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	validateCollectionForm(form)
+
+	if !form.Valid() {
+		app.renderCollection(w, r, c, form)
+		return
+	}
+
+	err = app.collections.Update(c.ID, form.Get("name"), form.Get("visibility"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Collection successfully updated")
+
+	http.Redirect(w, r, "/collection/"+c.ShortID, http.StatusSeeOther)
+}
+
+// deleteCollection deletes a collection for good. The snippets in it aren't
+// touched.
+func (app *application) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	c := app.ownedCollection(w, r)
+	if c == nil {
+		return
+	}
+
+	err := app.collections.Delete(c.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Collection deleted")
+
+	http.Redirect(w, r, "/collections/mine", http.StatusSeeOther)
+}
+
+// moveInCollection moves the snippet given by the 'snippet' form field one
+// place 'up' or 'down' in a collection, according to the 'direction' field.
+func (app *application) moveInCollection(w http.ResponseWriter, r *http.Request) {
+	c := app.ownedCollection(w, r)
+	if c == nil {
+		return
+	}
+
+	snippets, err := app.snippets.InCollection(c.ID, c.UserID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The snippet and direction come from hidden fields, so if either is
+	// invalid the form has been tampered with.
+	i := -1
+	for j, s := range snippets {
+		if s.ShortID == r.PostFormValue("snippet") {
+			i = j
+		}
+	}
+	j := i - 1
+	if r.PostFormValue("direction") == "down" {
+		j = i + 1
+	} else if r.PostFormValue("direction") != "up" {
+		i = -1
+	}
+	if i < 0 || j < 0 || j >= len(snippets) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	snippets[i], snippets[j] = snippets[j], snippets[i]
+
+	ids := make([]int, len(snippets))
+	for k, s := range snippets {
+		ids[k] = s.ID
+	}
+	err = app.collections.Reorder(c.ID, ids)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, "/collection/"+c.ShortID, http.StatusSeeOther)
+}
+
+// removeFromCollection takes the snippet given by the 'snippet' form field
+// out of a collection.
+func (app *application) removeFromCollection(w http.ResponseWriter, r *http.Request) {
+	c := app.ownedCollection(w, r)
+	if c == nil {
+		return
+	}
+
+	// The snippet isn't looked up first, as that would miss one which has
+	// been put in the trash or has expired since it was added, and the owner
+	// must be able to take those out too.
+	shortID := r.PostFormValue("snippet")
+	if !models.IsShortID(shortID) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err := app.collections.Remove(c.ID, shortID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", fmt.Sprintf("Snippet removed from %s", c.Name))
+
+	http.Redirect(w, r, "/collection/"+c.ShortID, http.StatusSeeOther)
+}
+
+// maxCommentLength is the longest comment we accept, in characters.
+const maxCommentLength = 1000
+
+// validateCommentForm runs the checks shared by the add and edit comment
+// forms.
+func validateCommentForm(form *forms.Form) {
+	form.Required("body")
+	form.MaxLength("body", maxCommentLength)
+}
+
+// addComment adds a comment by the current user to a snippet. If the form
+// has a 'parent' comment ID, the new comment is a reply to that comment.
+// Otherwise, if it has a range of 'lines', the comment is about those lines
+// of the snippet's main file as it is now.
+func (app *application) addComment(w http.ResponseWriter, r *http.Request) {
+	s := app.readableSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	// A burn-after-reading snippet is gone as soon as it's been read, so
+	// there's no page for the discussion to go on.
+	if s.BurnAfterReading {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 8192)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	validateCommentForm(form)
+
+	// The parent ID comes from a hidden field, so if it's invalid the form
+	// has been tampered with.
+	parentID := 0
+	if p := form.Get("parent"); p != "" {
+		parentID, err = strconv.Atoi(p)
+		if err != nil || parentID < 1 {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Unlike the parent, the lines are typed in, so a bad range is reported
+	// back on the form.
+	lineStart, lineEnd := 0, 0
+	if l := form.Get("lines"); l != "" && parentID == 0 {
+		lineStart, lineEnd, err = parseLineRange(l)
+		if s.Encrypted {
+			form.Errors.Add("lines", "Comments on lines aren't possible on an encrypted snippet")
+		} else if err != nil {
+			form.Errors.Add("lines", "This field must be a line or a range of lines, like 12 or 12-20")
+		} else if n := lineCount(s.Content); lineEnd > n {
+			// Only the main file's lines can be commented on, so for a
+			// snippet with further files we say which one is meant.
+			what := "This snippet"
+			if len(s.Files) > 0 {
+				what = snippetFilename(s)
+			}
+			if n == 1 {
+				form.Errors.Add("lines", what+" only has 1 line")
+			} else {
+				form.Errors.Add("lines", fmt.Sprintf("%s only has %d lines", what, n))
+			}
+		}
+	}
+
+	if !form.Valid() {
+		app.renderSnippet(w, r, s, form)
+		return
+	}
+
+	id, err := app.comments.Insert(s.ID, app.authenticatedUser(r).ID, parentID, lineStart, lineEnd, form.Get("body"))
+	if err == models.ErrNoRecord {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Comment successfully posted")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%s#comment-%d", s.ShortID, id), http.StatusSeeOther)
+}
+
+// ownedComment fetches the comment with the ID given by the ':id' URL
+// parameter, along with the snippet it's on, and checks that the comment was
+// written by the authenticated user. If the comment doesn't exist, its
+// snippet can't be viewed, or it was written by someone else, the
+// appropriate error response has already been sent and nil is returned.
+func (app *application) ownedComment(w http.ResponseWriter, r *http.Request) (*models.Comment, *models.Snippet) {
 	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
 	if err != nil || id < 1 {
-		app.notFound(w) // Use the notFound() helper.
-		return
+		app.notFound(w)
+		return nil, nil
 	}
 
-	// Use the SnippetModel object's Get method to retrieve the data for a
-	// specific record based on its ID. If no matching record is found,
-	// return a 404 Not Found response.
-	s, err := app.snippets.Get(id)
+	c, err := app.comments.Get(id)
 	if err == models.ErrNoRecord {
 		app.notFound(w)
-		return
+		return nil, nil
 	} else if err != nil {
 		app.serverError(w, err)
+		return nil, nil
+	}
+
+	// Comments go with their snippet, so once it has been deleted or has
+	// expired they can't be changed any more.
+	s, err := app.snippets.GetByID(c.SnippetID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return nil, nil
+	} else if err != nil {
+		app.serverError(w, err)
+		return nil, nil
+	}
+	if !app.canView(r, s) {
+		app.notFound(w)
+		return nil, nil
+	}
+
+	if c.UserID != app.authenticatedUser(r).ID {
+		app.clientError(w, http.StatusForbidden)
+		return nil, nil
+	}
+
+	return c, s
+}
+
+// editCommentForm shows the form for the current user to edit one of their
+// comments.
+func (app *application) editCommentForm(w http.ResponseWriter, r *http.Request) {
+	c, s := app.ownedComment(w, r)
+	if c == nil {
 		return
 	}
-	// Create an instance of a templateData struct holding the snippet data.
-	// Then, use the new render helper.
-	app.render(w, r, "show.page.tmpl", &templateData{
+
+	form := forms.New(url.Values{})
+	form.Set("body", c.Body)
+
+	app.render(w, r, "comment.page.tmpl", &templateData{
 		Snippet: s,
+		Comment: c,
+		Form:    form,
 	})
 }
 
-func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
-	app.render(w, r, "create.page.tmpl", &templateData{
-		// Pass a new empty forms.Form object to the template.
-		Form: forms.New(nil),
+// editComment saves the new body of one of the current user's comments.
+func (app *application) editComment(w http.ResponseWriter, r *http.Request) {
+	c, s := app.ownedComment(w, r)
+	if c == nil {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 8192)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	validateCommentForm(form)
+
+	if !form.Valid() {
+		app.render(w, r, "comment.page.tmpl", &templateData{Snippet: s, Comment: c, Form: form})
+		return
+	}
+
+	err = app.comments.Update(c.ID, form.Get("body"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Comment successfully updated")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%s#comment-%d", s.ShortID, c.ID), http.StatusSeeOther)
+}
+
+// deleteComment removes one of the current user's comments, along with any
+// replies to it.
+func (app *application) deleteComment(w http.ResponseWriter, r *http.Request) {
+	c, s := app.ownedComment(w, r)
+	if c == nil {
+		return
+	}
+
+	err := app.comments.Delete(c.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Comment successfully deleted")
+
+	http.Redirect(w, r, "/snippet/"+s.ShortID+"#comments", http.StatusSeeOther)
+}
+
+// The length limits on a snippet's password. bcrypt only looks at the first
+// 72 bytes of a password, and won't hash anything longer.
+const (
+	minPasswordLength = 8
+	maxPasswordBytes  = 72
+)
+
+// validatePassword checks the optional password on the create snippet form.
+func validatePassword(form *forms.Form) {
+	form.MinLength("password", minPasswordLength)
+	if len(form.Get("password")) > maxPasswordBytes {
+		form.Errors.Add("password", fmt.Sprintf("This field is too long (maximum is %d bytes)", maxPasswordBytes))
+	}
+}
+
+// validateSnippetForm runs the checks shared by the create and edit snippet
+// forms. The content isn't needed when an encrypted snippet's ciphertext is
+// sent instead.
+func validateSnippetForm(form *forms.Form) {
+	form.Required("title", "visibility")
+	if form.Get("ciphertext") == "" {
+		form.Required("content")
+	}
+	form.MaxLength("title", 100)
+	form.MaxLength("content", maxContentLength)
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate)
+	form.PermittedValues("language", languageNames()...)
+	form.MaxItems("tags", maxTags)
+	form.ItemsMatchPattern("tags", forms.TagRX)
+}
+
+// maxTags is the most tags a snippet can have.
+const maxTags = 5
+
+// formTags returns the tags entered on a snippet form. Tags aren't case
+// sensitive, so they are lower cased, and any duplicates are dropped.
+func formTags(form *forms.Form) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range form.List("tags") {
+		tag = strings.ToLower(tag)
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ownedSnippet fetches the snippet named by the ':id' URL parameter and checks
+// that it belongs to the authenticated user. If it doesn't exist, or belongs
+// to someone else, the appropriate error response has already been sent and
+// nil is returned.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	s := app.snippetFromURL(w, r)
+	if s == nil {
+		return nil
+	}
+
+	if !app.isOwner(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return nil
+	}
+
+	return s
+}
+
+// editableSnippet is like ownedSnippet, but also checks that the snippet
+// can be edited. An encrypted snippet can't be, since the server can't read
+// the content to put on the form. Nor can a snippet with further files, as
+// its revisions, history and diffs only cover the main file.
+func (app *application) editableSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	s := app.ownedSnippet(w, r)
+	if s == nil {
+		return nil
+	}
+
+	if s.Encrypted || len(s.Files) > 0 {
+		app.clientError(w, http.StatusBadRequest)
+		return nil
+	}
+
+	return s
+}
+
+func (app *application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s := app.editableSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	// Pre-populate the form with the current values of the snippet.
+	form := forms.New(url.Values{})
+	fillEditForm(form, s)
+
+	app.render(w, r, "edit.page.tmpl", &templateData{
+		Snippet: s,
+		Form:    form,
 	})
 }
 
-// createSnippet is a handler function.
-func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
-	// The check of r.Method != 'POST' is now superfluous and can be removed.
+// fillEditForm sets the fields of the edit form to the snippet's current
+// values. Every field has to be set, as any which are left empty are saved
+// empty when the form is submitted.
+func fillEditForm(form *forms.Form, s *models.Snippet) {
+	form.Set("title", s.Title)
+	form.Set("content", s.Content)
+	form.Set("language", s.Language)
+	form.Set("visibility", s.Visibility)
+	form.Set("tags", strings.Join(s.Tags, ", "))
+}
 
-	// if r.Method != "POST" {
-	// 	// Use the Header().Set() method to add an 'Allow: POST' header to the
-	// 	// response header map. The first parameter is the header name, and
-	// 	// the second parameter is the header value.
-	// 	w.Header().Set("Allow", "POST")
+func (app *application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.editableSnippet(w, r)
+	if s == nil {
+		return
+	}
 
-	// 	// Use the http.Error() function to send a 405 status code and "Method Not
-	// 	// Allowed" string as the response body.
-	// 	app.clientError(w, http.StatusMethodNotAllowed) // Use the clientError() helper.
-	// 	return
-	// }
+	r.Body = http.MaxBytesReader(w, r.Body, snippetFormSize)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	validateSnippetForm(form)
+
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", &templateData{Snippet: s, Form: form})
+		return
+	}
+
+	s.Title = form.Get("title")
+	s.Content = form.Get("content")
+	s.Language = form.Get("language")
+	s.Visibility = form.Get("visibility")
+	s.Tags = formTags(form)
+	err = app.snippets.Update(s, app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet successfully updated")
+
+	http.Redirect(w, r, "/snippet/"+s.ShortID, http.StatusSeeOther)
+}
+
+// editExpiry changes when a snippet expires. The new expiry is counted from
+// now, so it can be used to either extend or shorten the snippet's life.
+func (app *application) editExpiry(w http.ResponseWriter, r *http.Request) {
+	s := app.ownedSnippet(w, r)
+	if s == nil {
+		return
+	}
 
-	// Form size. Limit the request body size to 4096 bytes.
 	r.Body = http.MaxBytesReader(w, r.Body, 4096)
 
-	// First we call r.ParseForm() which adds any data in POST request bodies
-	// to the r.PostForm map. This also works in the same way for PUT and PATCH
-	// requests. If there are any errors, we use our app.ClientError helper to send
-	// a 400 Bad Request response to the user.
 	err := r.ParseForm()
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	// Create a new forms.Form struct containing the POSTed data from the
-	// form, then use the validation methods to check the content.
 	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
-	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
+	validateExpiry(form)
 
-	// If the form isn't valid, redisplay the template passing in the
-	// form.Form object as the data.
+	// The expiry form lives on the edit page, so if it isn't valid we
+	// redisplay that with the snippet's fields filled back in.
 	if !form.Valid() {
-		app.render(w, r, "create.page.tmpl", &templateData{Form: form})
+		fillEditForm(form, s)
+		app.render(w, r, "edit.page.tmpl", &templateData{Snippet: s, Form: form})
 		return
 	}
 
-	// Create a new snippet record in the database using the form data by
-	// passing the data to the SnippetModel.Insert() method, receiving the
-	// ID of the new record back.
-	id, err := app.snippets.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+	err = app.snippets.SetExpiry(s.ID, expiryTime(form, time.Now()))
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	// Use the Put() method to add a string value ('Your snippet was saved
-	// successfully!') and the corresponding key ('flash') to the session
-	// data. Note that if there's no existing session for the current user
-	// (or their session has expired) then a new, empty, session for them
-	// will automatically be created by the session middleware.
-	app.session.Put(r, "flash", "Snippet successfully created")
+	app.session.Put(r, "flash", "Snippet expiry successfully changed")
 
-	// Redirect the user to the relevant page for the snippet.
-	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, "/snippet/"+s.ShortID, http.StatusSeeOther)
+}
+
+func (app *application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.ownedSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	err := app.snippets.Delete(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet moved to the trash")
+
+	http.Redirect(w, r, "/snippet/trash", http.StatusSeeOther)
+}
+
+func (app *application) showTrash(w http.ResponseWriter, r *http.Request) {
+	s, err := app.snippets.Trash(app.authenticatedUser(r).ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "trash.page.tmpl", &templateData{
+		Snippets:      s,
+		RetentionDays: models.TrashRetentionDays,
+	})
+}
+
+func (app *application) restoreSnippet(w http.ResponseWriter, r *http.Request) {
+	shortID := r.URL.Query().Get(":id")
+	if !models.IsShortID(shortID) {
+		app.notFound(w)
+		return
+	}
+
+	// Restore is scoped to the authenticated user, so a snippet that belongs
+	// to somebody else looks exactly like one that isn't in the trash.
+	err := app.snippets.Restore(app.authenticatedUser(r).ID, shortID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet successfully restored")
+
+	http.Redirect(w, r, "/snippet/"+shortID, http.StatusSeeOther)
+}
+
+func (app *application) purgeSnippet(w http.ResponseWriter, r *http.Request) {
+	shortID := r.URL.Query().Get(":id")
+	if !models.IsShortID(shortID) {
+		app.notFound(w)
+		return
+	}
+
+	err := app.snippets.Purge(app.authenticatedUser(r).ID, shortID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.session.Put(r, "flash", "Snippet permanently deleted")
+
+	http.Redirect(w, r, "/snippet/trash", http.StatusSeeOther)
 }
 
 func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
 )
 
 func TestPing(t *testing.T) {
@@ -44,13 +53,19 @@ func TestShowSnippet(t *testing.T) {
 		wantCode int
 		wantBody []byte
 	}{
-		{"Valid ID", "/snippet/1", http.StatusOK, []byte("An old silent pond...")},
-		{"Non-existent ID", "/snippet/2", http.StatusNotFound, nil},
+		{"Valid ID", "/snippet/aB3dE5gH7j", http.StatusOK, []byte("An old silent pond...")},
+		{"Non-existent ID", "/snippet/zzzzzzzzzz", http.StatusNotFound, nil},
+		{"Legacy ID", "/snippet/1", http.StatusMovedPermanently, nil},
+		{"Non-existent legacy ID", "/snippet/2", http.StatusNotFound, nil},
 		{"Negative ID", "/snippet/-1", http.StatusNotFound, nil},
+		{"Zero-padded legacy ID", "/snippet/01", http.StatusNotFound, nil},
+		{"Signed legacy ID", "/snippet/+1", http.StatusNotFound, nil},
 		{"Decimal ID", "/snippet/1.23", http.StatusNotFound, nil},
 		{"String ID", "/snippet/foo", http.StatusNotFound, nil},
 		{"Empty ID", "/snippet/", http.StatusNotFound, nil},
-		{"Trailing slash", "/snippet/1/", http.StatusNotFound, nil},
+		{"Trailing slash", "/snippet/aB3dE5gH7j/", http.StatusNotFound, nil},
+		{"Private snippet", "/snippet/pR1vAtExYz", http.StatusNotFound, nil},
+		{"Private legacy ID", "/snippet/4", http.StatusNotFound, nil},
 	}
 
 	for _, tt := range tests {
@@ -66,6 +81,38 @@ func TestShowSnippet(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Legacy ID redirect", func(t *testing.T) {
+		_, header, _ := ts.get(t, "/snippet/1/history")
+
+		if loc := header.Get("Location"); loc != "/snippet/aB3dE5gH7j/history" {
+			t.Errorf("want %q; got %q", "/snippet/aB3dE5gH7j/history", loc)
+		}
+	})
+
+	// A private snippet is visible to its owner, but not to anyone else.
+	owners := []struct {
+		name      string
+		userEmail string
+		wantCode  int
+	}{
+		{"Private snippet as owner", "alice@foo.bar", http.StatusOK},
+		{"Private snippet as other user", "carol@foo.bar", http.StatusNotFound},
+	}
+
+	for _, tt := range owners {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+			ts.login(t, tt.userEmail)
+
+			code, _, _ := ts.get(t, "/snippet/pR1vAtExYz")
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
 }
 
 func TestSignupUser(t *testing.T) {
@@ -121,3 +168,1474 @@ func TestSignupUser(t *testing.T) {
 		})
 	}
 }
+
+func TestEditSnippet(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name      string
+		userEmail string
+		urlPath   string
+		title     string
+		content   string
+		wantCode  int
+		wantBody  []byte
+	}{
+		{"Anonymous user", "", "/snippet/aB3dE5gH7j/edit", "Title", "Content", http.StatusFound, nil},
+		{"Owner", "alice@foo.bar", "/snippet/aB3dE5gH7j/edit", "Title", "Content", http.StatusSeeOther, nil},
+		{"Empty title", "alice@foo.bar", "/snippet/aB3dE5gH7j/edit", "", "Content", http.StatusOK, []byte("This field cannot be blank")},
+		{"Longest content", "alice@foo.bar", "/snippet/aB3dE5gH7j/edit", "Title", strings.Repeat("é", 4096), http.StatusSeeOther, nil},
+		{"Long content", "alice@foo.bar", "/snippet/aB3dE5gH7j/edit", "Title", strings.Repeat("é", 4097), http.StatusOK, []byte("This field is too long (maximum is 4096 characters)")},
+		{"Not the owner", "carol@foo.bar", "/snippet/aB3dE5gH7j/edit", "Title", "Content", http.StatusForbidden, nil},
+		{"Snippet with further files", "alice@foo.bar", "/snippet/bUnDlE7fIl/edit", "Title", "Content", http.StatusBadRequest, nil},
+		{"Non-existent ID", "alice@foo.bar", "/snippet/zzzzzzzzzz/edit", "Title", "Content", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			var csrfToken string
+			if tt.userEmail != "" {
+				csrfToken = ts.login(t, tt.userEmail)
+			} else {
+				_, _, body := ts.get(t, "/user/login")
+				csrfToken = extractCSRFToken(t, body)
+			}
+
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("visibility", "public")
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEditExpiry(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t, "alice@foo.bar")
+
+	form := url.Values{}
+	form.Add("expires", "custom")
+	form.Add("expires_amount", "0")
+	form.Add("expires_unit", "days")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, body := ts.postForm(t, "/snippet/aB3dE5gH7j/expires", form)
+
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	// The edit form is shown again with every field filled in, so that
+	// saving it from there doesn't wipe anything out.
+	for _, want := range []string{"An old silent pond...", `value="haiku, poetry"`} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	form.Set("expires_amount", "2")
+	code, header, _ := ts.postForm(t, "/snippet/aB3dE5gH7j/expires", form)
+
+	if code != http.StatusSeeOther {
+		t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	if loc := header.Get("Location"); loc != "/snippet/aB3dE5gH7j" {
+		t.Errorf("want %q; got %q", "/snippet/aB3dE5gH7j", loc)
+	}
+}
+
+func TestTrashSnippet(t *testing.T) {
+	app := newTestApplication(t)
+
+	tests := []struct {
+		name      string
+		userEmail string
+		urlPath   string
+		wantCode  int
+	}{
+		{"Delete as owner", "alice@foo.bar", "/snippet/aB3dE5gH7j/delete", http.StatusSeeOther},
+		{"Delete as other user", "carol@foo.bar", "/snippet/aB3dE5gH7j/delete", http.StatusForbidden},
+		{"Restore as owner", "alice@foo.bar", "/snippet/tR4sHeDxYz/restore", http.StatusSeeOther},
+		{"Restore as other user", "carol@foo.bar", "/snippet/tR4sHeDxYz/restore", http.StatusNotFound},
+		{"Restore snippet not in trash", "alice@foo.bar", "/snippet/aB3dE5gH7j/restore", http.StatusNotFound},
+		{"Purge as owner", "alice@foo.bar", "/snippet/tR4sHeDxYz/purge", http.StatusSeeOther},
+		{"Purge as other user", "carol@foo.bar", "/snippet/tR4sHeDxYz/purge", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			form := url.Values{}
+			form.Add("csrf_token", ts.login(t, tt.userEmail))
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	t.Run("Missing CSRF token", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+		ts.login(t, "alice@foo.bar")
+
+		code, _, _ := ts.postForm(t, "/snippet/aB3dE5gH7j/delete", url.Values{})
+		if code != http.StatusBadRequest {
+			t.Errorf("want %d; got %d", http.StatusBadRequest, code)
+		}
+	})
+
+	t.Run("Trash page", func(t *testing.T) {
+		ts := newTestServer(t, app.routes())
+		defer ts.Close()
+		ts.login(t, "alice@foo.bar")
+
+		code, _, body := ts.get(t, "/snippet/trash")
+		if code != http.StatusOK {
+			t.Errorf("want %d; got %d", http.StatusOK, code)
+		}
+		for _, want := range []string{"Over the wintry forest", fmt.Sprintf("restored for %d days", models.TrashRetentionDays)} {
+			if !bytes.Contains(body, []byte(want)) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+	})
+}
+
+func TestShowDiff(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"History", "/snippet/aB3dE5gH7j/history", http.StatusOK, []byte("An old pond")},
+		{"Valid revisions", "/snippet/aB3dE5gH7j/diff?from=1&to=2", http.StatusOK, []byte("An old silent pond...")},
+		{"Missing revision", "/snippet/aB3dE5gH7j/diff?from=1", http.StatusBadRequest, nil},
+		{"Non-existent revision", "/snippet/aB3dE5gH7j/diff?from=1&to=3", http.StatusNotFound, nil},
+		{"Non-existent snippet", "/snippet/zzzzzzzzzz/diff?from=1&to=2", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestShowMarkdownSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantBody    []byte
+		notWantBody []byte
+	}{
+		{"Rendered", "/snippet/mArKd0wNxy", []byte("<em>old</em>"), []byte("<script>alert(1)</script>")},
+		{"Source", "/snippet/mArKd0wNxy?view=source", []byte("*old*"), []byte("<em>old</em>")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != http.StatusOK {
+				t.Errorf("want %d; got %d", http.StatusOK, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+
+			if bytes.Contains(body, tt.notWantBody) {
+				t.Errorf("want body not to contain %q", tt.notWantBody)
+			}
+		})
+	}
+}
+
+func TestListSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Default page size", "/snippets", http.StatusOK},
+		{"Page size", "/snippets?limit=100", http.StatusOK},
+		{"Page size too small", "/snippets?limit=0", http.StatusBadRequest},
+		{"Page size too large", "/snippets?limit=101", http.StatusBadRequest},
+		{"Invalid cursor", "/snippets?before=foo", http.StatusBadRequest},
+		{"Both cursors", "/snippets?before=MTIzLjQy&after=MTIzLjQy", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	// Page through the snippets one at a time, following the links, and
+	// check that we get back to where we started.
+	cursorRX := regexp.MustCompile(`/snippets\?(before|after)=([\w-]+)&limit=1`)
+	links := func(body []byte) map[string]string {
+		m := map[string]string{}
+		for _, match := range cursorRX.FindAllSubmatch(body, -1) {
+			m[string(match[1])] = "/snippets?" + string(match[1]) + "=" + string(match[2]) + "&limit=1"
+		}
+		return m
+	}
+
+	_, _, body := ts.get(t, "/snippets?limit=1")
+	if !bytes.Contains(body, []byte("Notes")) {
+		t.Errorf("want first page to contain %q", "Notes")
+	}
+	first := links(body)
+	if _, ok := first["after"]; ok {
+		t.Errorf("want first page not to link to a newer page")
+	}
+
+	if first["before"] == "" {
+		t.Fatal("want first page to link to an older page")
+	}
+	_, _, body = ts.get(t, first["before"])
+	if !bytes.Contains(body, []byte("An old silent pond")) {
+		t.Errorf("want second page to contain %q", "An old silent pond")
+	}
+	second := links(body)
+	if _, ok := second["before"]; ok {
+		t.Errorf("want last page not to link to an older page")
+	}
+
+	if second["after"] == "" {
+		t.Fatal("want second page to link to a newer page")
+	}
+	_, _, body = ts.get(t, second["after"])
+	if !bytes.Contains(body, []byte("Notes")) {
+		t.Errorf("want newer page to contain %q", "Notes")
+	}
+}
+
+func TestShowUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    []byte
+		notWantBody []byte
+	}{
+		{"Valid ID", "/user/1", http.StatusOK, []byte("An old silent pond"), []byte("Config")},
+		{"No snippets", "/user/2", http.StatusOK, []byte("Carol"), nil},
+		{"Non-existent ID", "/user/3", http.StatusNotFound, nil, nil},
+		{"Negative ID", "/user/-1", http.StatusNotFound, nil, nil},
+		{"String ID", "/user/foo", http.StatusNotFound, nil, nil},
+		{"Invalid page size", "/user/1?limit=0", http.StatusBadRequest, nil, nil},
+		{"Email not shown", "/user/1", http.StatusOK, []byte("Alice"), []byte("alice@foo.bar")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+
+			if tt.notWantBody != nil && bytes.Contains(body, tt.notWantBody) {
+				t.Errorf("want body not to contain %q", tt.notWantBody)
+			}
+		})
+	}
+}
+
+func TestMySnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/snippets/mine")
+	if code != http.StatusFound || header.Get("Location") != "/user/login" {
+		t.Errorf("want redirect to /user/login; got %d %q", code, header.Get("Location"))
+	}
+
+	ts.login(t, "alice@foo.bar")
+
+	code, _, body := ts.get(t, "/snippets/mine")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+
+	// The owner sees all of their snippets, whatever their visibility.
+	for _, want := range []string{"An old silent pond", "Config", "One time password"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestShowTag(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    []byte
+		notWantBody []byte
+	}{
+		{"Valid tag", "/tag/haiku", http.StatusOK, []byte("An old silent pond"), nil},
+		{"Upper case tag", "/tag/HAIKU", http.StatusOK, []byte("An old silent pond"), nil},
+		{"First page", "/tag/haiku?page=1", http.StatusOK, []byte("An old silent pond"), []byte("Newer")},
+		{"Past the last page", "/tag/haiku?page=2", http.StatusOK, []byte("nothing to see here"), nil},
+		{"Unused tag", "/tag/unused", http.StatusOK, []byte("nothing to see here"), nil},
+		{"Invalid tag", "/tag/-haiku", http.StatusNotFound, nil, nil},
+		{"Invalid page", "/tag/haiku?page=0", http.StatusBadRequest, nil, nil},
+		{"Non-numeric page", "/tag/haiku?page=two", http.StatusBadRequest, nil, nil},
+		{"Last page", "/tag/haiku?page=1000", http.StatusOK, []byte("nothing to see here"), nil},
+		{"Beyond the last page", "/tag/haiku?page=1001", http.StatusNotFound, nil, nil},
+		{"Huge page", "/tag/haiku?page=9223372036854775807", http.StatusNotFound, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+
+			if tt.notWantBody != nil && bytes.Contains(body, tt.notWantBody) {
+				t.Errorf("want body not to contain %q", tt.notWantBody)
+			}
+		})
+	}
+}
+
+func TestShowSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    []byte
+		notWantBody []byte
+	}{
+		{"No query", "/search", http.StatusOK, []byte(`name="q"`), []byte("No snippets match")},
+		{"Match", "/search?q=pond", http.StatusOK, []byte("An old silent <mark>pond</mark>..."), nil},
+		{"No match", "/search?q=frog", http.StatusOK, []byte("No snippets match your search."), nil},
+		{"Private snippet", "/search?q=hunter2", http.StatusOK, []byte("No snippets match your search."), nil},
+		{"Burn after reading", "/search?q=horse", http.StatusOK, []byte("No snippets match your search."), nil},
+		{"Past the last page", "/search?q=pond&page=2", http.StatusOK, []byte("No snippets match your search."), nil},
+		{"Invalid page", "/search?q=pond&page=0", http.StatusBadRequest, nil, nil},
+		{"Huge page", "/search?q=pond&page=9223372036854775807", http.StatusNotFound, nil, nil},
+		{"Query too long", "/search?q=" + strings.Repeat("a", 101), http.StatusBadRequest, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+
+			if tt.notWantBody != nil && bytes.Contains(body, tt.notWantBody) {
+				t.Errorf("want body not to contain %q", tt.notWantBody)
+			}
+		})
+	}
+}
+
+func TestRawSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantBody        []byte
+		wantDisposition string
+	}{
+		{"Raw", "/snippet/aB3dE5gH7j/raw", http.StatusOK, []byte("An old silent pond..."), ""},
+		{"Download", "/snippet/aB3dE5gH7j/download", http.StatusOK, []byte("An old silent pond..."), `attachment; filename=an-old-silent-pond.txt`},
+		{"Non-existent ID", "/snippet/zzzzzzzzzz/raw", http.StatusNotFound, nil, ""},
+		{"Private snippet", "/snippet/pR1vAtExYz/raw", http.StatusNotFound, nil, ""},
+		{"Burn after reading", "/snippet/bUrN4fTeRr/raw", http.StatusNotFound, nil, ""},
+		{"Burn after reading download", "/snippet/bUrN4fTeRr/download", http.StatusNotFound, nil, ""},
+		{"Download named file", "/snippet/bUnDlE7fIl/download", http.StatusOK, []byte("FROM golang:1.13\n"), `attachment; filename=Dockerfile`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			// The content must come back exactly as it was saved, with
+			// nothing around it.
+			if !bytes.Equal(body, tt.wantBody) {
+				t.Errorf("want body %q; got %q", tt.wantBody, body)
+			}
+
+			if ct := header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+				t.Errorf("want %q; got %q", "text/plain; charset=utf-8", ct)
+			}
+
+			if cd := header.Get("Content-Disposition"); cd != tt.wantDisposition {
+				t.Errorf("want %q; got %q", tt.wantDisposition, cd)
+			}
+		})
+	}
+}
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet *models.Snippet
+		want    string
+	}{
+		{"Title and language", &models.Snippet{Title: "Hello, World!", Language: "go"}, "hello-world.go"},
+		{"Auto-detected language", &models.Snippet{Title: "notes"}, "notes.txt"},
+		{"Unknown language", &models.Snippet{Title: "notes", Language: "klingon"}, "notes.txt"},
+		{"No usable title", &models.Snippet{ShortID: "aB3dE5gH7j", Title: "???", Language: "python"}, "aB3dE5gH7j.py"},
+		{"Long title", &models.Snippet{Title: strings.Repeat("a", 80), Language: "text"}, strings.Repeat("a", 50) + ".txt"},
+		{"Named file", &models.Snippet{Title: "Web server", Filename: "Dockerfile", Language: "docker"}, "Dockerfile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snippetFilename(tt.snippet); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestShowBundle(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/bUnDlE7fIl")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+
+	// Every file gets a section of its own, and only the main file's lines
+	// have the plain anchors that line comments point at.
+	for _, want := range []string{"Dockerfile", "run.sh", "config.yaml", `id="L1"`, `id="run.sh-L2"`, `id="config.yaml-L1"`, `href="/snippet/bUnDlE7fIl/zip"`} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	// Single file snippets don't offer a zip.
+	_, _, body = ts.get(t, "/snippet/aB3dE5gH7j")
+	if bytes.Contains(body, []byte("/zip")) {
+		t.Errorf("want body not to contain a zip link")
+	}
+}
+
+func TestDownloadZip(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantFiles       map[string]string
+		wantDisposition string
+	}{
+		{"Bundle", "/snippet/bUnDlE7fIl/zip", http.StatusOK, map[string]string{
+			"Dockerfile":  "FROM golang:1.13\n",
+			"run.sh":      "#!/bin/sh\nexec ./web\n",
+			"config.yaml": "addr: \":4000\"\n",
+		}, "attachment; filename=web-server.zip"},
+		{"Single file", "/snippet/aB3dE5gH7j/zip", http.StatusOK, map[string]string{
+			"an-old-silent-pond.txt": "An old silent pond...",
+		}, "attachment; filename=an-old-silent-pond.zip"},
+		{"Private snippet", "/snippet/pR1vAtExYz/zip", http.StatusNotFound, nil, ""},
+		{"Burn after reading", "/snippet/bUrN4fTeRr/zip", http.StatusNotFound, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			if ct := header.Get("Content-Type"); ct != "application/zip" {
+				t.Errorf("want %q; got %q", "application/zip", ct)
+			}
+
+			if cd := header.Get("Content-Disposition"); cd != tt.wantDisposition {
+				t.Errorf("want %q; got %q", tt.wantDisposition, cd)
+			}
+
+			zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			files := map[string]string{}
+			for _, f := range zr.File {
+				rc, err := f.Open()
+				if err != nil {
+					t.Fatal(err)
+				}
+				content, err := ioutil.ReadAll(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				files[f.Name] = string(content)
+			}
+
+			if !reflect.DeepEqual(files, tt.wantFiles) {
+				t.Errorf("want %v; got %v", tt.wantFiles, files)
+			}
+		})
+	}
+}
+
+func TestBurnSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// A GET request must not reveal the content of the snippet.
+	code, _, body := ts.get(t, "/snippet/bUrN4fTeRr")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if bytes.Contains(body, []byte("correct horse battery staple")) {
+		t.Errorf("want body not to contain the snippet content")
+	}
+
+	code, _, _ = ts.get(t, "/snippet/bUrN4fTeRr/history")
+	if code != http.StatusNotFound {
+		t.Errorf("want %d; got %d", http.StatusNotFound, code)
+	}
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, header, body := ts.postForm(t, "/snippet/bUrN4fTeRr/burn", form)
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("correct horse battery staple")) {
+		t.Errorf("want body to contain the snippet content")
+	}
+	if cc := header.Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("want %q; got %q", "no-store", cc)
+	}
+
+	code, _, _ = ts.postForm(t, "/snippet/aB3dE5gH7j/burn", form)
+	if code != http.StatusNotFound {
+		t.Errorf("want %d; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestForkSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The original shows how many times it has been forked, and the fork
+	// links back to it.
+	_, _, body := ts.get(t, "/snippet/aB3dE5gH7j")
+	if !bytes.Contains(body, []byte("1 fork<")) {
+		t.Errorf("want body to contain the fork count")
+	}
+	_, _, body = ts.get(t, "/snippet/mArKd0wNxy")
+	if !bytes.Contains(body, []byte(`forked from <a href="/snippet/aB3dE5gH7j">#aB3dE5gH7j</a>`)) {
+		t.Errorf("want body to link to the parent")
+	}
+
+	csrfToken := ts.login(t, "carol@foo.bar")
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Public snippet", "/snippet/aB3dE5gH7j/fork", http.StatusSeeOther, "/snippet/nEwSn1pPet/edit"},
+		{"Private snippet", "/snippet/pR1vAtExYz/fork", http.StatusNotFound, ""},
+		{"Burn after reading", "/snippet/bUrN4fTeRr/fork", http.StatusNotFound, ""},
+		{"Non-existent ID", "/snippet/zzzzzzzzzz/fork", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+}
+
+func TestStarSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	form := url.Values{}
+	code, _, _ := ts.postForm(t, "/snippet/aB3dE5gH7j/star", form)
+	if code != http.StatusBadRequest {
+		t.Errorf("want %d without a CSRF token; got %d", http.StatusBadRequest, code)
+	}
+
+	// Carol has already starred the haiku, so she's offered the chance to
+	// unstar it.
+	csrfToken := ts.login(t, "carol@foo.bar")
+	_, _, body := ts.get(t, "/snippet/aB3dE5gH7j")
+	if !bytes.Contains(body, []byte(`action="/snippet/aB3dE5gH7j/unstar"`)) {
+		t.Errorf("want body to contain the unstar form")
+	}
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Star", "/snippet/aB3dE5gH7j/star", http.StatusSeeOther, "/snippet/aB3dE5gH7j"},
+		{"Star again", "/snippet/aB3dE5gH7j/star", http.StatusSeeOther, "/snippet/aB3dE5gH7j"},
+		{"Unstar", "/snippet/aB3dE5gH7j/unstar", http.StatusSeeOther, "/snippet/aB3dE5gH7j"},
+		{"Unstar again", "/snippet/aB3dE5gH7j/unstar", http.StatusSeeOther, "/snippet/aB3dE5gH7j"},
+		{"Private snippet", "/snippet/pR1vAtExYz/star", http.StatusNotFound, ""},
+		{"Burn after reading", "/snippet/bUrN4fTeRr/star", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+
+	code, _, body = ts.get(t, "/snippets/starred")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("An old silent pond")) {
+		t.Errorf("want body to contain %q", "An old silent pond")
+	}
+}
+
+func TestPopular(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Default window", "/snippets/popular", http.StatusOK, []byte(`href="/snippets/popular?window=week" class="live"`)},
+		{"All time", "/snippets/popular?window=all", http.StatusOK, []byte("An old silent pond")},
+		{"Invalid window", "/snippets/popular?window=decade", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/aB3dE5gH7j")
+	for _, want := range []string{`id="comment-1"`, "Written on a rainy afternoon.", `id="comment-2"`, "Frogs jump in, splash!", "to join the discussion",
+		`id="L1"`, `href="#L1"`, `id="comment-3"`, "On line 1 of an earlier version", "An old pond..."} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	csrfToken := ts.login(t, "carol@foo.bar")
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		body         string
+		parent       string
+		lines        string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Comment", "/snippet/aB3dE5gH7j/comments", "Nice.", "", "", http.StatusSeeOther, "/snippet/aB3dE5gH7j#comment-4", nil},
+		{"Reply", "/snippet/aB3dE5gH7j/comments", "Nice.", "1", "", http.StatusSeeOther, "/snippet/aB3dE5gH7j#comment-4", nil},
+		{"Blank comment", "/snippet/aB3dE5gH7j/comments", " ", "", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Long comment", "/snippet/aB3dE5gH7j/comments", strings.Repeat("a", 1001), "", "", http.StatusOK, "", []byte("This field is too long (maximum is 1000 characters)")},
+		{"Line comment", "/snippet/aB3dE5gH7j/comments", "Nice.", "", "1", http.StatusSeeOther, "/snippet/aB3dE5gH7j#comment-4", nil},
+		{"Line range", "/snippet/aB3dE5gH7j/comments", "Nice.", "", "L1-L1", http.StatusSeeOther, "/snippet/aB3dE5gH7j#comment-4", nil},
+		{"Lines past the end", "/snippet/aB3dE5gH7j/comments", "Nice.", "", "1-3", http.StatusOK, "", []byte("This snippet only has 1 line")},
+		{"Lines past the main file", "/snippet/bUnDlE7fIl/comments", "Nice.", "", "2", http.StatusOK, "", []byte("Dockerfile only has 1 line")},
+		{"Invalid lines", "/snippet/aB3dE5gH7j/comments", "Nice.", "", "3-1", http.StatusOK, "", []byte("This field must be a line or a range of lines")},
+		{"Reply ignores lines", "/snippet/aB3dE5gH7j/comments", "Nice.", "1", "x", http.StatusSeeOther, "/snippet/aB3dE5gH7j#comment-4", nil},
+		{"Invalid parent", "/snippet/aB3dE5gH7j/comments", "Nice.", "x", "", http.StatusBadRequest, "", nil},
+		{"Non-existent parent", "/snippet/aB3dE5gH7j/comments", "Nice.", "99", "", http.StatusBadRequest, "", nil},
+		{"Private snippet", "/snippet/pR1vAtExYz/comments", "Nice.", "", "", http.StatusNotFound, "", nil},
+		{"Burn after reading", "/snippet/bUrN4fTeRr/comments", "Nice.", "", "", http.StatusNotFound, "", nil},
+		{"Edit own comment", "/comment/2/edit", "Plop!", "", "", http.StatusSeeOther, "/snippet/aB3dE5gH7j#comment-2", nil},
+		{"Edit someone else's comment", "/comment/1/edit", "Plop!", "", "", http.StatusForbidden, "", nil},
+		{"Edit non-existent comment", "/comment/99/edit", "Plop!", "", "", http.StatusNotFound, "", nil},
+		{"Edit with blank comment", "/comment/2/edit", "", "", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Delete own comment", "/comment/2/delete", "", "", "", http.StatusSeeOther, "/snippet/aB3dE5gH7j#comments", nil},
+		{"Delete someone else's comment", "/comment/1/delete", "", "", "", http.StatusForbidden, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("parent", tt.parent)
+			form.Add("lines", tt.lines)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, loc)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	// Only the author of a comment gets the links to edit and delete it.
+	_, _, body = ts.get(t, "/snippet/aB3dE5gH7j")
+	if !bytes.Contains(body, []byte(`href="/comment/2/edit"`)) {
+		t.Errorf("want body to contain the edit link for Carol's comment")
+	}
+	if bytes.Contains(body, []byte(`href="/comment/1/edit"`)) {
+		t.Errorf("want body not to contain the edit link for Alice's comment")
+	}
+}
+
+func TestCreateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t, "alice@foo.bar")
+
+	tests := []struct {
+		name     string
+		expires  string
+		amount   string
+		tags     string
+		password string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Preset expiry", "1w", "", "", "", http.StatusSeeOther, nil},
+		{"Never expires", "never", "", "", "", http.StatusSeeOther, nil},
+		{"Custom expiry", "custom", "90", "", "", http.StatusSeeOther, nil},
+		{"Custom expiry out of range", "custom", "1000", "", "", http.StatusOK, []byte("This field must be a whole number between 1 and 999")},
+		{"Invalid expiry", "365", "", "", "", http.StatusOK, []byte("This field is invalid")},
+		{"Tags", "1w", "", "haiku, Poetry,, japan", "", http.StatusSeeOther, nil},
+		{"Too many tags", "1w", "", "a, b, c, d, e, f", "", http.StatusOK, []byte("This field has too many items (maximum is 5)")},
+		{"Invalid tag", "1w", "", "haiku, two words", "", http.StatusOK, []byte("This field contains an invalid item (two words)")},
+		{"Password", "1w", "", "", "open sesame", http.StatusSeeOther, nil},
+		{"Short password", "1w", "", "", "sesame", http.StatusOK, []byte("This field is too short (minimum is 8 characters)")},
+		{"Long password", "1w", "", "", strings.Repeat("a", 73), http.StatusOK, []byte("This field is too long (maximum is 72 bytes)")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "O snail")
+			form.Add("content", "O snail\nClimb Mount Fuji,\nBut slowly, slowly!")
+			form.Add("visibility", "public")
+			form.Add("expires", tt.expires)
+			form.Add("expires_amount", tt.amount)
+			form.Add("expires_unit", "minutes")
+			form.Add("tags", tt.tags)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if code == http.StatusSeeOther && header.Get("Location") != "/snippet/nEwSn1pPet" {
+				t.Errorf("want %q; got %q", "/snippet/nEwSn1pPet", header.Get("Location"))
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCreateSnippetFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t, "alice@foo.bar")
+
+	tests := []struct {
+		name      string
+		filenames []string
+		languages []string
+		contents  []string
+		button    [2]string
+		wantCode  int
+		wantBody  []string
+	}{
+		{"Bundle", []string{"Dockerfile", "run.sh", ""}, []string{"docker", "bash", "yaml"}, []string{"FROM golang:1.13", "exec ./web", "addr: :4000"}, [2]string{}, http.StatusSeeOther, nil},
+		{"Add file", []string{"Dockerfile"}, []string{"docker"}, []string{"FROM golang:1.13"}, [2]string{"add", "file"}, http.StatusOK, []string{"FROM golang:1.13", `value="1">Remove file`}},
+		{"Remove file", []string{"Dockerfile", "run.sh"}, []string{"docker", "bash"}, []string{"FROM golang:1.13", "exec ./web"}, [2]string{"remove", "0"}, http.StatusOK, []string{"exec ./web", `value="run.sh"`}},
+		{"Blank file", []string{"Dockerfile", "run.sh"}, []string{"docker", "bash"}, []string{"FROM golang:1.13", " "}, [2]string{}, http.StatusOK, []string{"This field cannot be blank"}},
+		{"Invalid filename", []string{"../Dockerfile"}, []string{"docker"}, []string{"FROM golang:1.13"}, [2]string{}, http.StatusOK, []string{"This field is invalid"}},
+		{"Duplicate filename", []string{"run.sh", "RUN.sh"}, []string{"bash", "bash"}, []string{"exec ./web", "exec ./web"}, [2]string{}, http.StatusOK, []string{"Another file already has this name"}},
+		{"Duplicate default filename", []string{"Dockerfile", "", "file2.sh"}, []string{"docker", "bash", "bash"}, []string{"FROM golang:1.13", "exec ./web", "exec ./web"}, [2]string{}, http.StatusOK, []string{"Another file already has this name"}},
+		{"Invalid language", []string{"Dockerfile", "run.sh"}, []string{"docker", "klingon"}, []string{"FROM golang:1.13", "exec ./web"}, [2]string{}, http.StatusOK, []string{"This field is invalid"}},
+		{"Long main file", []string{"Dockerfile", "run.sh"}, []string{"docker", "bash"}, []string{strings.Repeat("#", 4097), "exec ./web"}, [2]string{}, http.StatusOK, []string{"This field is too long (maximum is 4096 characters)"}},
+		{"Long file", []string{"Dockerfile", "run.sh"}, []string{"docker", "bash"}, []string{"FROM golang:1.13", strings.Repeat("#", 4097)}, [2]string{}, http.StatusOK, []string{"This field is too long (maximum is 4096 characters)"}},
+		{"Longest files", []string{"Dockerfile", "run.sh"}, []string{"docker", "bash"}, []string{strings.Repeat("é", 4096), strings.Repeat("é", 4096)}, [2]string{}, http.StatusSeeOther, nil},
+		{"Too many files", make([]string, 11), make([]string, 11), strings.Split("abcdefghijk", ""), [2]string{}, http.StatusOK, []string{"A snippet can have at most 10 files"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Web server")
+			form["filename"] = tt.filenames
+			form["language"] = tt.languages
+			form["content"] = tt.contents
+			form.Add("visibility", "public")
+			form.Add("expires", "never")
+			form.Add("csrf_token", csrfToken)
+			if tt.button[0] != "" {
+				form.Add(tt.button[0], tt.button[1])
+			}
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			for _, want := range tt.wantBody {
+				if !bytes.Contains(body, []byte(want)) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestProtectedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Until it's unlocked, neither the snippet page nor any of the other
+	// pages give away the content.
+	code, _, body := ts.get(t, "/snippet/l0cKeDsNip")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("This snippet is password protected")) {
+		t.Errorf("want body to contain the unlock form")
+	}
+	if bytes.Contains(body, []byte("postgres://staging")) {
+		t.Errorf("want body not to contain the snippet content")
+	}
+	for _, urlPath := range []string{"/snippet/l0cKeDsNip/raw", "/snippet/l0cKeDsNip/zip", "/snippet/l0cKeDsNip/history"} {
+		if code, _, _ := ts.get(t, urlPath); code != http.StatusForbidden {
+			t.Errorf("%s: want %d; got %d", urlPath, http.StatusForbidden, code)
+		}
+	}
+
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		password     string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Wrong password", "open barley", http.StatusOK, "", []byte("Password is incorrect")},
+		{"Right password", "open sesame", http.StatusSeeOther, "/snippet/l0cKeDsNip", nil},
+		{"Already unlocked", "", http.StatusSeeOther, "/snippet/l0cKeDsNip", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/snippet/l0cKeDsNip/unlock", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, loc)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	// Once unlocked, the snippet stays unlocked for the rest of the session.
+	_, _, body = ts.get(t, "/snippet/l0cKeDsNip")
+	if !bytes.Contains(body, []byte("postgres://staging")) {
+		t.Errorf("want body to contain the snippet content")
+	}
+	if code, _, _ := ts.get(t, "/snippet/l0cKeDsNip/raw"); code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+}
+
+func TestProtectedSnippetOwner(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The owner never needs the password.
+	ts.login(t, "alice@foo.bar")
+	_, _, body := ts.get(t, "/snippet/l0cKeDsNip")
+	if !bytes.Contains(body, []byte("postgres://staging")) {
+		t.Errorf("want body to contain the snippet content")
+	}
+}
+
+func TestUnlockRateLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/l0cKeDsNip")
+	csrfToken := extractCSRFToken(t, body)
+
+	unlock := func(password string) int {
+		form := url.Values{}
+		form.Add("password", password)
+		form.Add("csrf_token", csrfToken)
+		code, _, _ := ts.postForm(t, "/snippet/l0cKeDsNip/unlock", form)
+		return code
+	}
+
+	for i := 0; i < maxUnlockFailures; i++ {
+		if code := unlock("open barley"); code != http.StatusOK {
+			t.Fatalf("attempt %d: want %d; got %d", i+1, http.StatusOK, code)
+		}
+	}
+
+	// After too many wrong passwords, even the right one is refused.
+	if code := unlock("open sesame"); code != http.StatusTooManyRequests {
+		t.Errorf("want %d; got %d", http.StatusTooManyRequests, code)
+	}
+}
+
+func TestEncryptedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	envelope := "v1.AQEBAQEBAQEBAQEB.AgICAgICAgICAgICAgICAgICAgI"
+
+	// The page only carries the envelope, for the browser to decrypt, and
+	// none of the ways to change the content.
+	csrfToken := ts.login(t, "alice@foo.bar")
+	code, _, body := ts.get(t, "/snippet/eNcRyPt3dX")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{`data-envelope="` + envelope + `"`, "(encrypted)"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+	for _, unwanted := range []string{`class="chroma"`, "/snippet/eNcRyPt3dX/edit", "/snippet/eNcRyPt3dX/fork", `name="lines"`} {
+		if bytes.Contains(body, []byte(unwanted)) {
+			t.Errorf("want body not to contain %q", unwanted)
+		}
+	}
+
+	// The raw content and the download are the envelope itself.
+	code, _, body = ts.get(t, "/snippet/eNcRyPt3dX/raw")
+	if code != http.StatusOK || string(body) != envelope {
+		t.Errorf("want %d %q; got %d %q", http.StatusOK, envelope, code, body)
+	}
+	rs, err := ts.Client().Get(ts.URL + "/snippet/eNcRyPt3dX/download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.Body.Close()
+	if want := `attachment; filename=production-keys.txt.enc`; rs.Header.Get("Content-Disposition") != want {
+		t.Errorf("want %q; got %q", want, rs.Header.Get("Content-Disposition"))
+	}
+
+	// The server can't read the content, so it can't be edited, forked or
+	// commented on line by line.
+	if code, _, _ := ts.get(t, "/snippet/eNcRyPt3dX/edit"); code != http.StatusBadRequest {
+		t.Errorf("edit: want %d; got %d", http.StatusBadRequest, code)
+	}
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	if code, _, _ := ts.postForm(t, "/snippet/eNcRyPt3dX/fork", form); code != http.StatusBadRequest {
+		t.Errorf("fork: want %d; got %d", http.StatusBadRequest, code)
+	}
+	form.Add("body", "Which keys?")
+	form.Add("lines", "1")
+	if code, _, _ := ts.postForm(t, "/snippet/eNcRyPt3dX/comments", form); code != http.StatusOK {
+		t.Errorf("line comment: want %d; got %d", http.StatusOK, code)
+	}
+}
+
+func TestCreateEncryptedSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	csrfToken := ts.login(t, "alice@foo.bar")
+
+	iv := "AQEBAQEBAQEBAQEB"
+	tests := []struct {
+		name       string
+		ciphertext string
+		contents   []string
+		fields     map[string]string
+		wantCode   int
+		wantBody   []byte
+	}{
+		{"Encrypted", "v1." + iv + ".AgICAgICAgICAgICAgICAgICAgI", nil, nil, http.StatusSeeOther, nil},
+		{"Not an envelope", "An old silent pond...", nil, nil, http.StatusOK, []byte("This field must be an encrypted envelope")},
+		{"Too large", "v1." + iv + "." + strings.Repeat("A", 21848), nil, nil, http.StatusOK, []byte("This field is too long (maximum is 16384 bytes of ciphertext)")},
+		{"Plain text as well", "v1." + iv + ".AgICAgICAgICAgICAgICAgICAgI", []string{"An old silent pond..."}, nil, http.StatusOK, []byte("An encrypted snippet can only have one file, with no plain text content")},
+		{"Several files", "v1." + iv + ".AgICAgICAgICAgICAgICAgICAgI", []string{"", "exec ./web"}, nil, http.StatusOK, []byte("An encrypted snippet can only have one file, with no plain text content")},
+		{"Burn after reading", "v1." + iv + ".AgICAgICAgICAgICAgICAgICAgI", nil, map[string]string{"burn": "true"}, http.StatusOK, []byte("An encrypted snippet can&#39;t be burnt after reading or have a password")},
+		{"Password", "v1." + iv + ".AgICAgICAgICAgICAgICAgICAgI", nil, map[string]string{"password": "open sesame"}, http.StatusOK, []byte("An encrypted snippet can&#39;t be burnt after reading or have a password")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Production keys")
+			form.Add("ciphertext", tt.ciphertext)
+			form["content"] = tt.contents
+			for k, v := range tt.fields {
+				form.Add(k, v)
+			}
+			form.Add("visibility", "unlisted")
+			form.Add("expires", "never")
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if code == http.StatusSeeOther && header.Get("Location") != "/snippet/nEwSn1pPet" {
+				t.Errorf("want %q; got %q", "/snippet/nEwSn1pPet", header.Get("Location"))
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCountView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	get := func(urlPath, userAgent, referrer string) {
+		req, err := http.NewRequest("GET", ts.URL+urlPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set("Referer", referrer)
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+	}
+	counted := func() map[models.ViewCount]int {
+		app.viewCounter.mu.Lock()
+		defer app.viewCounter.mu.Unlock()
+		counts := map[models.ViewCount]int{}
+		for k, v := range app.viewCounter.counts {
+			k.Day = time.Time{}
+			counts[k] = v
+		}
+		return counts
+	}
+
+	browser := "Mozilla/5.0 (X11; Linux x86_64; rv:84.0) Gecko/20100101 Firefox/84.0"
+
+	// A visitor is only counted once per session, however often they look,
+	// and bots aren't counted at all.
+	get("/snippet/aB3dE5gH7j", browser, "https://news.ycombinator.com/item?id=1")
+	get("/snippet/aB3dE5gH7j", browser, "")
+	get("/snippet/bUnDlE7fIl", "Googlebot/2.1", "")
+	want := map[models.ViewCount]int{{SnippetID: 1, Domain: "news.ycombinator.com"}: 1}
+	if got := counted(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+
+	// Nor are the owner's own views.
+	ts.login(t, "alice@foo.bar")
+	get("/snippet/bUnDlE7fIl", browser, "")
+	if got := counted(); !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
+
+func TestShowStats(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, header, _ := ts.get(t, "/snippet/aB3dE5gH7j/stats")
+	if code != http.StatusFound || header.Get("Location") != "/user/login" {
+		t.Errorf("want %d to %q; got %d to %q", http.StatusFound, "/user/login", code, header.Get("Location"))
+	}
+
+	ts.login(t, "carol@foo.bar")
+	if code, _, _ := ts.get(t, "/snippet/aB3dE5gH7j/stats"); code != http.StatusForbidden {
+		t.Errorf("want %d; got %d", http.StatusForbidden, code)
+	}
+
+	ts.login(t, "alice@foo.bar")
+	code, _, body := ts.get(t, "/snippet/aB3dE5gH7j/stats")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{
+		"Viewed 6 times in the last 30 days",
+		"and 10 times in all",
+		time.Now().UTC().Format("02 Jan 2006"),
+		`style="width: 50%"`,
+		"news.ycombinator.com",
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestShowCollection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		email      string
+		urlPath    string
+		wantCode   int
+		wantBody   []string
+		unwantBody []string
+	}{
+		{"Public", "", "/collection/cOlLeCt10n", http.StatusOK, []string{"Haiku", "An old silent pond", "Notes"}, []string{"Staging database", "Config", "Delete collection"}},
+		{"Private", "", "/collection/pR1vAtEc0l", http.StatusNotFound, nil, nil},
+		{"Invalid short ID", "", "/collection/foo", http.StatusNotFound, nil, nil},
+		{"Non-existent", "", "/collection/zZzZzZzZzZ", http.StatusNotFound, nil, nil},
+		{"Other user", "carol@foo.bar", "/collection/cOlLeCt10n", http.StatusOK, []string{"Haiku"}, []string{"Staging database", "Config", "Delete collection"}},
+		{"Other user private", "carol@foo.bar", "/collection/pR1vAtEc0l", http.StatusNotFound, nil, nil},
+		{"Owner", "alice@foo.bar", "/collection/cOlLeCt10n", http.StatusOK, []string{"Staging database", "Config", "Delete collection", `value="up"`, `value="down"`}, nil},
+		{"Owner private", "alice@foo.bar", "/collection/pR1vAtEc0l", http.StatusOK, []string{"Drafts", "(private)"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.email != "" {
+				ts.login(t, tt.email)
+			}
+
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			for _, want := range tt.wantBody {
+				if !bytes.Contains(body, []byte(want)) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+			for _, unwanted := range tt.unwantBody {
+				if bytes.Contains(body, []byte(unwanted)) {
+					t.Errorf("want body not to contain %q", unwanted)
+				}
+			}
+		})
+	}
+
+	// Public collections are listed on their owner's profile.
+	_, _, body := ts.get(t, "/user/1")
+	if !bytes.Contains(body, []byte(`<a href="/collection/cOlLeCt10n">Haiku</a>`)) {
+		t.Errorf("want profile to list the public collection")
+	}
+	if bytes.Contains(body, []byte("Drafts")) {
+		t.Errorf("want profile not to list the private collection")
+	}
+}
+
+func TestEditCollections(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	carolToken := ts.login(t, "carol@foo.bar")
+	form := url.Values{}
+	form.Add("name", "Mine now")
+	form.Add("visibility", "public")
+	form.Add("csrf_token", carolToken)
+	if code, _, _ := ts.postForm(t, "/collection/cOlLeCt10n/edit", form); code != http.StatusForbidden {
+		t.Errorf("want %d; got %d", http.StatusForbidden, code)
+	}
+
+	csrfToken := ts.login(t, "alice@foo.bar")
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		fields       map[string]string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Create", "/collection/create", map[string]string{"name": "Onboarding scripts", "visibility": "unlisted"}, http.StatusSeeOther, "/collection/nEwC0lLect", nil},
+		{"Create without a name", "/collection/create", map[string]string{"name": "", "visibility": "public"}, http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Create with invalid visibility", "/collection/create", map[string]string{"name": "Scripts", "visibility": "secret"}, http.StatusOK, "", []byte("This field is invalid")},
+		{"Edit", "/collection/cOlLeCt10n/edit", map[string]string{"name": "Poems", "visibility": "private"}, http.StatusSeeOther, "/collection/cOlLeCt10n", nil},
+		{"Edit with a long name", "/collection/cOlLeCt10n/edit", map[string]string{"name": strings.Repeat("a", 101), "visibility": "public"}, http.StatusOK, "", []byte("This field is too long (maximum is 100 characters)")},
+		{"Move down", "/collection/cOlLeCt10n/move", map[string]string{"snippet": "aB3dE5gH7j", "direction": "down"}, http.StatusSeeOther, "/collection/cOlLeCt10n", nil},
+		{"Move up", "/collection/cOlLeCt10n/move", map[string]string{"snippet": "mArKd0wNxy", "direction": "up"}, http.StatusSeeOther, "/collection/cOlLeCt10n", nil},
+		{"Move first up", "/collection/cOlLeCt10n/move", map[string]string{"snippet": "aB3dE5gH7j", "direction": "up"}, http.StatusBadRequest, "", nil},
+		{"Move last down", "/collection/cOlLeCt10n/move", map[string]string{"snippet": "pR1vAtExYz", "direction": "down"}, http.StatusBadRequest, "", nil},
+		{"Move sideways", "/collection/cOlLeCt10n/move", map[string]string{"snippet": "mArKd0wNxy", "direction": "left"}, http.StatusBadRequest, "", nil},
+		{"Move snippet not in it", "/collection/cOlLeCt10n/move", map[string]string{"snippet": "bUnDlE7fIl", "direction": "up"}, http.StatusBadRequest, "", nil},
+		{"Remove", "/collection/cOlLeCt10n/remove", map[string]string{"snippet": "aB3dE5gH7j"}, http.StatusSeeOther, "/collection/cOlLeCt10n", nil},
+		{"Remove trashed snippet", "/collection/cOlLeCt10n/remove", map[string]string{"snippet": "tR4sHeDxYz"}, http.StatusSeeOther, "/collection/cOlLeCt10n", nil},
+		{"Remove invalid snippet", "/collection/cOlLeCt10n/remove", map[string]string{"snippet": "foo"}, http.StatusBadRequest, "", nil},
+		{"Delete", "/collection/cOlLeCt10n/delete", nil, http.StatusSeeOther, "/collections/mine", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			for k, v := range tt.fields {
+				form.Add(k, v)
+			}
+			form.Add("csrf_token", csrfToken)
+
+			code, header, body := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, loc)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	_, _, body := ts.get(t, "/collections/mine")
+	for _, want := range []string{"Drafts", "Haiku", "Create collection"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestCollectSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// The snippet page offers the owner's collections which don't already
+	// hold the snippet, and a way out of the ones that do.
+	csrfToken := ts.login(t, "alice@foo.bar")
+	_, _, body := ts.get(t, "/snippet/aB3dE5gH7j")
+	for _, want := range []string{`In <a href="/collection/cOlLeCt10n">Haiku</a>`, `<option value="pR1vAtEc0l">Drafts</option>`} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		collection   string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Add", "/snippet/aB3dE5gH7j/collect", "pR1vAtEc0l", http.StatusSeeOther, "/snippet/aB3dE5gH7j"},
+		{"Remove", "/snippet/aB3dE5gH7j/uncollect", "cOlLeCt10n", http.StatusSeeOther, "/snippet/aB3dE5gH7j"},
+		{"Burn after reading", "/snippet/bUrN4fTeRr/collect", "pR1vAtEc0l", http.StatusBadRequest, ""},
+		{"Missing collection", "/snippet/aB3dE5gH7j/collect", "", http.StatusBadRequest, ""},
+		{"Non-existent collection", "/snippet/aB3dE5gH7j/collect", "zZzZzZzZzZ", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("collection", tt.collection)
+			form.Add("csrf_token", csrfToken)
+
+			code, header, _ := ts.postForm(t, tt.urlPath, form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := header.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, loc)
+			}
+		})
+	}
+
+	// Nobody can add snippets to someone else's collection.
+	carolToken := ts.login(t, "carol@foo.bar")
+	form := url.Values{}
+	form.Add("collection", "cOlLeCt10n")
+	form.Add("csrf_token", carolToken)
+	if code, _, _ := ts.postForm(t, "/snippet/aB3dE5gH7j/collect", form); code != http.StatusForbidden {
+		t.Errorf("want %d; got %d", http.StatusForbidden, code)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// language is a language a snippet can be highlighted as. Name is the name
+// of the chroma lexer, which is what gets stored with the snippet, and Ext is
+// the file extension used when the snippet is downloaded.
+type language struct {
+	Name  string
+	Label string
+	Ext   string
+}
+
+// languages lists the supported languages, in the order they are offered on
+// the snippet forms.
+var languages = []language{
+	{"bash", "Bash", ".sh"},
+	{"c", "C", ".c"},
+	{"cpp", "C++", ".cpp"},
+	{"css", "CSS", ".css"},
+	{"diff", "Diff", ".diff"},
+	{"docker", "Dockerfile", ".dockerfile"},
+	{"go", "Go", ".go"},
+	{"html", "HTML", ".html"},
+	{"ini", "INI", ".ini"},
+	{"java", "Java", ".java"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"markdown", "Markdown", ".md"},
+	{"php", "PHP", ".php"},
+	{"python", "Python", ".py"},
+	{"ruby", "Ruby", ".rb"},
+	{"rust", "Rust", ".rs"},
+	{"sql", "SQL", ".sql"},
+	{"toml", "TOML", ".toml"},
+	{"typescript", "TypeScript", ".ts"},
+	{"yaml", "YAML", ".yaml"},
+	{"text", "Plain text", ".txt"},
+}
+
+// languageOptions returns the supported languages, for use in templates.
+func languageOptions() []language {
+	return languages
+}
+
+// languageNames returns the names of all the supported languages.
+func languageNames() []string {
+	names := make([]string, len(languages))
+	for i, l := range languages {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// languageLabel returns the human-readable label for a language name, or the
+// empty string if the language isn't one we know about.
+func languageLabel(name string) string {
+	for _, l := range languages {
+		if l.Name == name {
+			return l.Label
+		}
+	}
+	return ""
+}
+
+// languageExt returns the file extension for a language name. Snippets in a
+// language we don't know about are saved as plain text.
+func languageExt(name string) string {
+	for _, l := range languages {
+		if l.Name == name {
+			return l.Ext
+		}
+	}
+	return ".txt"
+}
+
+// newHighlightFormatter returns a formatter which renders tokens as HTML with
+// CSS classes, rather than inline styles. The classes are styled by
+// ui/static/css/highlight.css. Each line is numbered, and its number links to
+// an anchor made of the prefix and the line number.
+func newHighlightFormatter(prefix string) *html.Formatter {
+	return html.New(
+		html.WithClasses(true),
+		html.WithLineNumbers(true),
+		html.LinkableLineNumbers(true, prefix),
+	)
+}
+
+// highlightFormatter is the formatter for a snippet's main file, whose lines
+// have anchors like "#L12".
+var highlightFormatter = newHighlightFormatter("L")
+
+// highlight returns content as syntax highlighted HTML. If language is empty,
+// or isn't a language chroma knows, we try to work out the language from the
+// content itself, and failing that leave it as plain text.
+func highlight(content, language string) template.HTML {
+	return highlightWith(highlightFormatter, content, language)
+}
+
+// highlightFile is like highlight, but for the further files of a multi-file
+// snippet. Their line anchors are prefixed with the file's name, like
+// "#run.sh-L12", so that they don't clash with the main file's.
+func highlightFile(f *models.File) template.HTML {
+	return highlightWith(newHighlightFormatter(f.Name+"-L"), f.Content, f.Language)
+}
+
+// highlightWith highlights content using the given formatter.
+func highlightWith(formatter *html.Formatter, content, language string) template.HTML {
+	var lexer chroma.Lexer
+	if language != "" {
+		lexer = lexers.Get(language)
+	}
+	if lexer == nil {
+		lexer = lexers.Analyse(content)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	buf := new(bytes.Buffer)
+	iterator, err := lexer.Tokenise(nil, content)
+	if err == nil {
+		err = formatter.Format(buf, styles.Get("github"), iterator)
+	}
+
+	// Highlighting is only decoration, so if it fails for whatever reason we
+	// fall back to showing the content as it is.
+	if err != nil {
+		return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+	}
+
+	return template.HTML(buf.String())
+}
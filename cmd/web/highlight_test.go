@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/lexers"
+)
+
+func TestLanguages(t *testing.T) {
+	// Every language we offer on the forms must be one chroma knows by name,
+	// otherwise snippets saved with it would silently lose their highlighting.
+	for _, l := range languages {
+		if lexers.Get(l.Name) == nil {
+			t.Errorf("no lexer for language %q", l.Name)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		wantBody []string
+	}{
+		{
+			name:     "Go",
+			content:  "package main",
+			language: "go",
+			wantBody: []string{`class="chroma"`, `<span class="kn">package</span>`},
+		},
+		{
+			name:     "Plain text",
+			content:  "An old silent pond...",
+			language: "text",
+			wantBody: []string{`class="chroma"`, "An old silent pond..."},
+		},
+		{
+			name:     "Unknown language",
+			content:  "An old silent pond...",
+			language: "klingon",
+			wantBody: []string{`class="chroma"`, "An old silent pond..."},
+		},
+		{
+			name:     "Line anchors",
+			content:  "package main\n\nfunc main() {}\n",
+			language: "go",
+			wantBody: []string{`id="L1"`, `href="#L1"`, `id="L3"`, `href="#L3"`},
+		},
+		{
+			name:     "Escapes HTML",
+			content:  "<script>alert(1)</script>",
+			language: "text",
+			wantBody: []string{"&lt;script&gt;"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(highlight(tt.content, tt.language))
+
+			for _, want := range tt.wantBody {
+				if !strings.Contains(got, want) {
+					t.Errorf("want body to contain %q; got %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHighlightLineCount(t *testing.T) {
+	// The line numbers we validate line comments against must be the same
+	// ones shown next to the snippet.
+	for _, content := range []string{
+		"An old silent pond...",
+		"An old silent pond...\n",
+		"An old silent pond...\r\nA frog jumps into the pond,\r\n",
+		"An old silent pond...\n\n",
+	} {
+		got := strings.Count(string(highlight(content, "text")), `id="L`)
+		if want := lineCount(content); got != want {
+			t.Errorf("%q: want %d numbered lines; got %d", content, want, got)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter keeps count of attempts at something, such as guessing a snippet's
+// password, and refuses further attempts under a key once it has had max of
+// them within the window. Attempts older than the window are forgotten, and a
+// successful attempt forgets all of them.
+//
+// An attempt is counted as soon as it's made, before anyone knows whether it
+// will succeed, so that a burst of attempts made at the same time can't all
+// get in while the first of them are still being checked.
+type limiter struct {
+	max    int
+	window time.Duration
+	// now gives the time an attempt is made at, and that the window ends
+	// at. It's time.Now outside of tests.
+	now func() time.Time
+
+	mu        sync.Mutex
+	attempts  map[string][]time.Time
+	lastSweep time.Time
+}
+
+// newLimiter returns a limiter allowing max attempts per key within window.
+func newLimiter(max int, window time.Duration) *limiter {
+	return &limiter{
+		max:      max,
+		window:   window,
+		now:      time.Now,
+		attempts: map[string][]time.Time{},
+	}
+}
+
+// attempt reports whether another attempt under key may be made, and if so
+// counts it.
+func (l *limiter) attempt(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	times := l.recent(key, now)
+	if len(times) >= l.max {
+		return false
+	}
+	l.attempts[key] = append(times, now)
+
+	// Keys which stop being used would otherwise stay in the map for good,
+	// so once per window we clear out every key with nothing recent left.
+	if now.Sub(l.lastSweep) >= l.window {
+		for k := range l.attempts {
+			l.recent(k, now)
+		}
+		l.lastSweep = now
+	}
+
+	return true
+}
+
+// succeed forgets the attempts under key, after one of them has succeeded.
+func (l *limiter) succeed(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+}
+
+// recent returns the attempts under key which are still within the window
+// at now, dropping the rest. The caller must hold l.mu.
+func (l *limiter) recent(key string, now time.Time) []time.Time {
+	times := l.attempts[key]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= l.window {
+		i++
+	}
+	times = times[i:]
+	if len(times) == 0 {
+		delete(l.attempts, key)
+		return nil
+	}
+	l.attempts[key] = times
+	return times
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2020, 12, 17, 10, 0, 0, 0, time.UTC)
+	l := newLimiter(3, 15*time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !l.attempt("a") {
+			t.Fatalf("want attempt %d to be allowed", i+1)
+		}
+		now = now.Add(time.Minute)
+	}
+
+	if l.attempt("a") {
+		t.Errorf("want attempt after 3 attempts to be refused")
+	}
+
+	// Other keys are counted separately.
+	if !l.attempt("b") {
+		t.Errorf("want attempt under another key to be allowed")
+	}
+
+	// Once the first attempt is older than the window, there's room for one
+	// more.
+	now = now.Add(12 * time.Minute)
+	if !l.attempt("a") {
+		t.Errorf("want attempt to be allowed once the first one has expired")
+	}
+	if l.attempt("a") {
+		t.Errorf("want attempt to be refused again after another one")
+	}
+
+	// A success forgets the attempts before it.
+	l.succeed("a")
+	if !l.attempt("a") {
+		t.Errorf("want attempt to be allowed after a success")
+	}
+
+	// Keys with nothing recent are swept out of the map.
+	now = now.Add(time.Hour)
+	l.attempt("c")
+	if _, ok := l.attempts["a"]; ok {
+		t.Errorf("want expired key to have been swept")
+	}
+	if len(l.attempts) != 1 {
+		t.Errorf("want 1 key left; got %d", len(l.attempts))
+	}
+}
+
+func TestLimiterConcurrent(t *testing.T) {
+	l := newLimiter(5, 15*time.Minute)
+
+	// However many attempts arrive at once, no more than max get through.
+	var wg sync.WaitGroup
+	allowed := make(chan bool, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			allowed <- l.attempt("a")
+		}()
+	}
+	wg.Wait()
+	close(allowed)
+
+	n := 0
+	for ok := range allowed {
+		if ok {
+			n++
+		}
+	}
+	if n != 5 {
+		t.Errorf("want 5 attempts allowed; got %d", n)
+	}
+}
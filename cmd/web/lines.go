@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// errInvalidLineRange is returned when a range of lines can't be parsed.
+var errInvalidLineRange = errors.New("invalid line range")
+
+// parseLineRange parses a range of lines, given either as a single line
+// number or as a first and last line separated by a hyphen. The lines can be
+// written the way they appear in the snippet page's anchors too, so "12",
+// "12-20", "L12" and "L12-L20" are all accepted.
+func parseLineRange(s string) (int, int, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "-", 2)
+
+	lines := make([]int, len(parts))
+	for i, part := range parts {
+		part = strings.TrimPrefix(strings.TrimSpace(part), "L")
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 {
+			return 0, 0, errInvalidLineRange
+		}
+		lines[i] = n
+	}
+
+	start, end := lines[0], lines[len(lines)-1]
+	if end < start {
+		return 0, 0, errInvalidLineRange
+	}
+	return start, end, nil
+}
+
+// lineCount returns the number of lines in content, counted the same way as
+// the line numbers shown alongside it. A final line break doesn't start a new
+// line.
+func lineCount(content string) int {
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+}
+
+// lineAnchor returns the fragment identifying a range of lines on the
+// snippet page, like "L12" or "L12-L20".
+func lineAnchor(start, end int) string {
+	if end <= start {
+		return fmt.Sprintf("L%d", start)
+	}
+	return fmt.Sprintf("L%d-L%d", start, end)
+}
@@ -0,0 +1,69 @@
+package main
+
+import "testing"
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         string
+		wantStart int
+		wantEnd   int
+		wantErr   error
+	}{
+		{"Single line", "12", 12, 12, nil},
+		{"Range", "12-20", 12, 20, nil},
+		{"Anchor", "L12", 12, 12, nil},
+		{"Anchor range", "L12-L20", 12, 20, nil},
+		{"Spaces", " 12 - 20 ", 12, 20, nil},
+		{"Same line twice", "12-12", 12, 12, nil},
+		{"Backwards", "20-12", 0, 0, errInvalidLineRange},
+		{"Zero", "0", 0, 0, errInvalidLineRange},
+		{"Negative", "-12", 0, 0, errInvalidLineRange},
+		{"Open ended", "12-", 0, 0, errInvalidLineRange},
+		{"Not a number", "twelve", 0, 0, errInvalidLineRange},
+		{"Too many parts", "1-2-3", 0, 0, errInvalidLineRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := parseLineRange(tt.s)
+			if err != tt.wantErr {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+			if start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("want %d-%d; got %d-%d", tt.wantStart, tt.wantEnd, start, end)
+			}
+		})
+	}
+}
+
+func TestLineCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+	}{
+		{"Single line", "An old silent pond...", 1},
+		{"Trailing line break", "An old silent pond...\n", 1},
+		{"Several lines", "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.", 3},
+		{"Windows line breaks", "An old silent pond...\r\nA frog jumps into the pond,\r\n", 2},
+		{"Blank line at the end", "An old silent pond...\n\n", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineCount(tt.content); got != tt.want {
+				t.Errorf("want %d; got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLineAnchor(t *testing.T) {
+	if got := lineAnchor(12, 12); got != "L12" {
+		t.Errorf("want %q; got %q", "L12", got)
+	}
+	if got := lineAnchor(12, 20); got != "L12-L20" {
+		t.Errorf("want %q; got %q", "L12-L20", got)
+	}
+}
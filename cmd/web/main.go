@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
 	"flag"
@@ -8,6 +9,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -28,12 +31,58 @@ type application struct {
 	errorLog *log.Logger
 	infoLog  *log.Logger
 	snippets interface {
-		Insert(string, string, string) (int, error)
-		Get(int) (*models.Snippet, error)
+		Insert(*models.Snippet) (string, error)
+		Get(string) (*models.Snippet, error)
+		GetByID(int) (*models.Snippet, error)
+		Burn(string) (*models.Snippet, error)
+		Update(*models.Snippet, int) error
+		SetExpiry(int, time.Time) error
+		Revisions(int) ([]*models.Revision, error)
+		Revision(int, int) (*models.Revision, error)
+		Delete(int) error
+		Trash(int) ([]*models.Snippet, error)
+		Restore(int, string) error
+		Purge(int, string) error
 		Latest() ([]*models.Snippet, error)
+		List(*models.Cursor, bool, int) ([]*models.Snippet, error)
+		ByUser(int, bool, *models.Cursor, bool, int) ([]*models.Snippet, error)
+		Tagged(string, int, int) ([]*models.Snippet, error)
+		Search(string, int) ([]*models.Snippet, bool, error)
+		Star(int, int) error
+		Unstar(int, int) error
+		Starred(int, int) (bool, error)
+		StarredBy(int, *models.Cursor, bool, int) ([]*models.Snippet, error)
+		Popular(time.Time, int) ([]*models.Snippet, error)
+		Unlock(int, string) error
+		InCollection(int, int) ([]*models.Snippet, error)
 	}
+	collections interface {
+		Insert(int, string, string) (string, error)
+		Get(string) (*models.Collection, error)
+		ByUser(int, bool) ([]*models.Collection, error)
+		ForSnippet(int, int) ([]*models.Collection, error)
+		Update(int, string, string) error
+		Delete(int) error
+		Add(int, int) error
+		Remove(int, string) error
+		Reorder(int, []int) error
+	}
+	comments interface {
+		Insert(int, int, int, int, int, string) (int, error)
+		Get(int) (*models.Comment, error)
+		ForSnippet(int) ([]*models.Comment, error)
+		Update(int, string) error
+		Delete(int) error
+	}
+	views interface {
+		Daily(int, time.Time) ([]*models.DailyViews, error)
+		Referrers(int, time.Time, int) ([]*models.ReferrerViews, error)
+		Total(int) (int, error)
+	}
+	viewCounter   *viewCounter
 	templateCache map[string]*template.Template
 	session       *sessions.Session
+	unlockLimiter *limiter
 	users         interface {
 		Insert(string, string, string) error
 		Authenticate(string, string) (int, error)
@@ -55,6 +104,10 @@ func main() {
 	// bytes long.
 	secret := flag.String("secret", "s6Ndh+pPbnzHbS*+9Pk8qGWhTzbpa@ge", "Secret key")
 
+	// Define a new command-line flag for how often expired snippets are
+	// purged from the database.
+	reapInterval := flag.Duration("reap-interval", 10*time.Minute, "Interval between purges of expired snippets")
+
 	// Importantly, we use the flag.Parse() function to parse the command-line flag.
 	// This reads in the command-line flag value and assigns it to the addr
 	// variable. You need to call this *before* you use the addr variable
@@ -74,6 +127,12 @@ func main() {
 	// file name and line number.
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	// A ticker panics if it's given an interval which isn't positive, and it
+	// would do so inside the reaper's goroutine, so catch that here instead.
+	if *reapInterval <= 0 {
+		errorLog.Fatal("-reap-interval must be positive")
+	}
+
 	// To keep the main() function tidy I've put the code for creating a connection
 	// pool into the separate openDB() function below. We pass openDB() the DSN
 	// from the command-line flag.
@@ -112,13 +171,23 @@ func main() {
 	session.Secure = true
 	session.SameSite = http.SameSiteStrictMode
 
+	// Views are counted up in memory, and added to the database a minute's
+	// worth at a time.
+	views := &mysql.ViewModel{DB: db}
+	viewCounter := newViewCounter(errorLog, views, time.Minute)
+
 	// Initialize a new instance of application containing the dependencies.
 	app := &application{
 		errorLog:      errorLog,
 		infoLog:       infoLog,
 		snippets:      &mysql.SnippetModel{DB: db},
+		comments:      &mysql.CommentModel{DB: db},
+		collections:   &mysql.CollectionModel{DB: db},
+		views:         views,
+		viewCounter:   viewCounter,
 		templateCache: templateCache,
 		session:       session,
+		unlockLimiter: newLimiter(maxUnlockFailures, unlockWindow),
 		users:         &mysql.UserModel{DB: db},
 	}
 
@@ -144,6 +213,40 @@ func main() {
 		MaxHeaderBytes: 524288, // limit the maximum header length to 0.5MB
 	}
 
+	// Start the reaper, which purges expired snippets from the database in
+	// the background for as long as the server runs.
+	rp := &reaper{
+		errorLog:  errorLog,
+		infoLog:   infoLog,
+		snippets:  &mysql.SnippetModel{DB: db},
+		interval:  *reapInterval,
+		batchSize: 500,
+		now:       time.Now,
+	}
+	rp.start()
+	viewCounter.start()
+
+	// When we're asked to stop with SIGINT or SIGTERM, shut the server down
+	// gracefully. This makes ListenAndServeTLS() below return
+	// http.ErrServerClosed straight away, but Shutdown() itself only returns
+	// once in-flight requests have finished (or the timeout is up), so done
+	// is closed after that.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+
+		infoLog.Print("Shutting down server")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			errorLog.Print(err)
+		}
+	}()
+
 	// Use the http.ListenAndServe() function to start a new web server. We pass in
 	// two parameters: the TCP network address to listen on (in this case ":4000")
 	// and the servemux we just created. Write messages using the two new loggers,
@@ -154,7 +257,18 @@ func main() {
 	// http.Server struct. We pass in the paths to the TLS certificate and
 	// corresponding private key as the two parameters.
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+	if err != http.ErrServerClosed {
+		errorLog.Fatal(err)
+	}
+
+	// Wait for in-flight requests to finish, then for the reaper to finish
+	// what it's doing, and for the last views to be saved, before the
+	// deferred db.Close() runs. Views are only recorded by handlers, so with
+	// them all finished the view counter's last flush saves every one.
+	<-done
+	rp.stop()
+	viewCounter.stop()
+	infoLog.Print("Server stopped")
 }
 
 // The openDB() function wraps sql.Open() and returns a sql.DB connection pool
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdownRenderer converts GitHub flavoured Markdown to HTML. Raw HTML in
+// the Markdown is passed through as it is, because everything it outputs
+// goes through markdownPolicy before being shown.
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(html.WithUnsafe()),
+)
+
+// markdownPolicy is the sanitizer for rendered Markdown. It allows the
+// elements and attributes found in user generated content, but strips
+// scripts, styles, inline event handlers and unsafe URLs.
+var markdownPolicy = bluemonday.UGCPolicy()
+
+// markdown renders content as Markdown and returns the sanitized HTML. If it
+// can't be rendered, the content is shown as preformatted text instead.
+func markdown(content string) template.HTML {
+	buf := new(bytes.Buffer)
+	if err := markdownRenderer.Convert([]byte(content), buf); err != nil {
+		return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+	}
+
+	return template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes()))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantBody    []string
+		notWantBody []string
+	}{
+		{
+			name:     "Heading",
+			content:  "# An old silent pond",
+			wantBody: []string{"<h1>An old silent pond</h1>"},
+		},
+		{
+			name:     "Table",
+			content:  "| a | b |\n|---|---|\n| 1 | 2 |",
+			wantBody: []string{"<table>", "<td>1</td>"},
+		},
+		{
+			name:        "Script",
+			content:     "Hello <script>alert(1)</script>",
+			wantBody:    []string{"Hello"},
+			notWantBody: []string{"<script", "alert(1)"},
+		},
+		{
+			name:        "Event handler",
+			content:     `<img src="x.png" onerror="alert(1)">`,
+			wantBody:    []string{`<img src="x.png"`},
+			notWantBody: []string{"onerror"},
+		},
+		{
+			name:        "JavaScript link",
+			content:     "[click me](javascript:alert(1))",
+			wantBody:    []string{"click me"},
+			notWantBody: []string{"javascript:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(markdown(tt.content))
+
+			for _, want := range tt.wantBody {
+				if !strings.Contains(got, want) {
+					t.Errorf("want body to contain %q; got %q", want, got)
+				}
+			}
+			for _, notWant := range tt.notWantBody {
+				if strings.Contains(got, notWant) {
+					t.Errorf("want body not to contain %q; got %q", notWant, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// reaper periodically removes expired snippets, and snippets which have been
+// in the trash for longer than the retention window, from the database. The
+// snippet pages already hide them, but without the reaper the rows would
+// stay in the table forever.
+type reaper struct {
+	errorLog *log.Logger
+	infoLog  *log.Logger
+	snippets interface {
+		PurgeExpired(time.Time, int) (int, error)
+	}
+	// interval is the time between runs, and batchSize the most snippets
+	// removed by a single statement, so that a big backlog doesn't hold
+	// locks on the table for long.
+	interval  time.Duration
+	batchSize int
+	// now gives the moment a run measures expiry and time in the trash
+	// against. It's time.Now outside of tests.
+	now func() time.Time
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// start runs the reaper in a background goroutine until stop is called.
+func (rp *reaper) start() {
+	rp.quit = make(chan struct{})
+	rp.wg.Add(1)
+	go func() {
+		defer rp.wg.Done()
+
+		ticker := time.NewTicker(rp.interval)
+		defer ticker.Stop()
+
+		for {
+			rp.reap()
+
+			select {
+			case <-ticker.C:
+			case <-rp.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop tells the background goroutine to finish and waits until it has. A
+// run which is already under way finishes its current batch first.
+func (rp *reaper) stop() {
+	close(rp.quit)
+	rp.wg.Wait()
+}
+
+// reap removes everything which is due for removal, one batch at a time, and
+// logs how many snippets went.
+func (rp *reaper) reap() {
+	now := rp.now()
+	total := 0
+	for {
+		n, err := rp.snippets.PurgeExpired(now, rp.batchSize)
+		if err != nil {
+			rp.errorLog.Printf("reaper: %s", err)
+			break
+		}
+		total += n
+		if n < rp.batchSize || rp.stopping() {
+			break
+		}
+	}
+
+	if total > 0 {
+		rp.infoLog.Printf("Reaper purged %d expired snippets", total)
+	}
+}
+
+// stopping reports whether stop has been called.
+func (rp *reaper) stopping() bool {
+	select {
+	case <-rp.quit:
+		return true
+	default:
+		return false
+	}
+}
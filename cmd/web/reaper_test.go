@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSnippetPurger records the calls made to it by the reaper, and reports
+// removing snippets from a pretend backlog.
+type fakeSnippetPurger struct {
+	mu      sync.Mutex
+	backlog int
+	calls   []time.Time
+}
+
+func (f *fakeSnippetPurger) PurgeExpired(now time.Time, limit int) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, now)
+	n := limit
+	if f.backlog < n {
+		n = f.backlog
+	}
+	f.backlog -= n
+	return n, nil
+}
+
+func TestReaperReap(t *testing.T) {
+	now := time.Date(2020, 12, 17, 10, 0, 0, 0, time.UTC)
+	purger := &fakeSnippetPurger{backlog: 25}
+	infoLog := new(bytes.Buffer)
+
+	rp := &reaper{
+		errorLog:  log.New(ioutil.Discard, "", 0),
+		infoLog:   log.New(infoLog, "", 0),
+		snippets:  purger,
+		batchSize: 10,
+		now:       func() time.Time { return now },
+	}
+	rp.reap()
+
+	// A backlog of 25 with batches of 10 takes three statements, all given
+	// the time from the injected clock.
+	if len(purger.calls) != 3 {
+		t.Errorf("want 3 batches; got %d", len(purger.calls))
+	}
+	for _, call := range purger.calls {
+		if !call.Equal(now) {
+			t.Errorf("want %v; got %v", now, call)
+		}
+	}
+
+	if !strings.Contains(infoLog.String(), "purged 25 expired snippets") {
+		t.Errorf("want log %q to contain the number of snippets purged", infoLog.String())
+	}
+}
+
+func TestReaperStop(t *testing.T) {
+	rp := &reaper{
+		errorLog:  log.New(ioutil.Discard, "", 0),
+		infoLog:   log.New(ioutil.Discard, "", 0),
+		snippets:  &fakeSnippetPurger{},
+		interval:  time.Hour,
+		batchSize: 10,
+		now:       time.Now,
+	}
+	rp.start()
+
+	stopped := make(chan struct{})
+	go func() {
+		rp.stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("reaper didn't stop")
+	}
+}
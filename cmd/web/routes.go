@@ -25,8 +25,45 @@ func (app *application) routes() http.Handler {
 	// exact match routes before any wildcard routes.
 	mux.Get("/snippet/create", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createSnippetForm))
 	mux.Post("/snippet/create", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createSnippet))
+	mux.Get("/snippet/trash", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.showTrash))
 	// Wildcard routes.
 	mux.Get("/snippet/:id", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Post("/snippet/:id/burn", dynamicMiddleware.ThenFunc(app.burnSnippet))
+	mux.Post("/snippet/:id/unlock", dynamicMiddleware.ThenFunc(app.unlockSnippet))
+	mux.Get("/snippet/:id/raw", dynamicMiddleware.ThenFunc(app.rawSnippet))
+	mux.Get("/snippet/:id/download", dynamicMiddleware.ThenFunc(app.downloadSnippet))
+	mux.Get("/snippet/:id/zip", dynamicMiddleware.ThenFunc(app.downloadZip))
+	mux.Get("/snippet/:id/history", dynamicMiddleware.ThenFunc(app.showHistory))
+	mux.Get("/snippet/:id/diff", dynamicMiddleware.ThenFunc(app.showDiff))
+	mux.Get("/snippet/:id/stats", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.showStats))
+	mux.Get("/snippet/:id/edit", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.editSnippetForm))
+	mux.Post("/snippet/:id/edit", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.editSnippet))
+	mux.Post("/snippet/:id/star", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.starSnippet))
+	mux.Post("/snippet/:id/unstar", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.unstarSnippet))
+	mux.Post("/snippet/:id/comments", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.addComment))
+	mux.Post("/snippet/:id/collect", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.collectSnippet))
+	mux.Post("/snippet/:id/uncollect", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.uncollectSnippet))
+	mux.Post("/snippet/:id/fork", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.forkSnippet))
+	mux.Post("/snippet/:id/expires", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.editExpiry))
+	mux.Post("/snippet/:id/delete", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.deleteSnippet))
+	mux.Post("/snippet/:id/restore", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.restoreSnippet))
+	mux.Post("/snippet/:id/purge", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.purgeSnippet))
+	mux.Get("/snippets", dynamicMiddleware.ThenFunc(app.listSnippets))
+	mux.Get("/snippets/mine", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.mySnippets))
+	mux.Get("/snippets/starred", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.myStars))
+	mux.Get("/snippets/popular", dynamicMiddleware.ThenFunc(app.popular))
+	mux.Get("/collections/mine", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.myCollections))
+	mux.Post("/collection/create", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.createCollection))
+	mux.Get("/collection/:id", dynamicMiddleware.ThenFunc(app.showCollection))
+	mux.Post("/collection/:id/edit", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.editCollection))
+	mux.Post("/collection/:id/delete", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.deleteCollection))
+	mux.Post("/collection/:id/move", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.moveInCollection))
+	mux.Post("/collection/:id/remove", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.removeFromCollection))
+	mux.Get("/comment/:id/edit", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.editCommentForm))
+	mux.Post("/comment/:id/edit", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.editComment))
+	mux.Post("/comment/:id/delete", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.deleteComment))
+	mux.Get("/tag/:name", dynamicMiddleware.ThenFunc(app.showTag))
+	mux.Get("/search", dynamicMiddleware.ThenFunc(app.showSearch))
 
 	// User authentication routes.
 	mux.Get("/user/signup", dynamicMiddleware.ThenFunc(app.signupUserForm))
@@ -34,6 +71,7 @@ func (app *application) routes() http.Handler {
 	mux.Get("/user/login", dynamicMiddleware.ThenFunc(app.loginUserForm))
 	mux.Post("/user/login", dynamicMiddleware.ThenFunc(app.loginUser))
 	mux.Post("/user/logout", dynamicMiddleware.Append(app.requireAuthenticatedUser).ThenFunc(app.logoutUser))
+	mux.Get("/user/:id", dynamicMiddleware.ThenFunc(app.showUser))
 
 	// Register the ping handler function as the handler for the GET /ping
 	// route.
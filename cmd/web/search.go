@@ -0,0 +1,94 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+	"unicode"
+)
+
+// excerptLength is the number of characters of content shown for each
+// search result.
+const excerptLength = 160
+
+// excerptLead is the number of characters shown before the first match, so
+// that it's seen in context.
+const excerptLead = 40
+
+// searchTerms splits a search query into the lower cased words it is made of.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// excerpt returns the part of content around the first match for the search
+// query, as HTML with every match wrapped in a <mark> element. If nothing
+// matches, the start of the content is returned instead.
+func excerpt(content, query string) template.HTML {
+	terms := searchTerms(query)
+
+	// Work in runes, lower casing each one on its own, so that positions in
+	// the lower cased copy line up with positions in the original content.
+	text := []rune(content)
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	start := 0
+	if i := firstMatch(lower, terms); i > excerptLead {
+		start = i - excerptLead
+	}
+	end := start + excerptLength
+	if end > len(text) {
+		end = len(text)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		if n := matchAt(lower, i, terms); n > 0 {
+			if i+n > end {
+				n = end - i
+			}
+			b.WriteString("<mark>")
+			b.WriteString(template.HTMLEscapeString(string(text[i : i+n])))
+			b.WriteString("</mark>")
+			i += n
+			continue
+		}
+		b.WriteString(template.HTMLEscapeString(string(text[i])))
+		i++
+	}
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return template.HTML(b.String())
+}
+
+// firstMatch returns the position of the first match for any of the terms in
+// text, or -1 if there isn't one.
+func firstMatch(text []rune, terms []string) int {
+	for i := range text {
+		if matchAt(text, i, terms) > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchAt returns the length of the longest of the terms found at position i
+// of text, or zero if none of them are.
+func matchAt(text []rune, i int, terms []string) int {
+	longest := 0
+	for _, term := range terms {
+		t := []rune(term)
+		if len(t) > longest && i+len(t) <= len(text) && string(text[i:i+len(t)]) == term {
+			longest = len(t)
+		}
+	}
+	return longest
+}
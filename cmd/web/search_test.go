@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("a ", 100) + "silent pond" + strings.Repeat(" b", 100)
+
+	tests := []struct {
+		name    string
+		content string
+		query   string
+		want    string
+	}{
+		{
+			name:    "Match",
+			content: "An old silent pond...",
+			query:   "pond",
+			want:    "An old silent <mark>pond</mark>...",
+		},
+		{
+			name:    "Case insensitive",
+			content: "An old silent pond...",
+			query:   "OLD Pond",
+			want:    "An <mark>old</mark> silent <mark>pond</mark>...",
+		},
+		{
+			name:    "No match",
+			content: "An old silent pond...",
+			query:   "frog",
+			want:    "An old silent pond...",
+		},
+		{
+			name:    "Escapes HTML",
+			content: "<b>pond</b>",
+			query:   "pond",
+			want:    "&lt;b&gt;<mark>pond</mark>&lt;/b&gt;",
+		},
+		{
+			name:    "Long content",
+			content: long,
+			query:   "pond",
+			want:    "…" + strings.Repeat(" a", 16) + " silent <mark>pond</mark>" + strings.Repeat(" b", 58) + "…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(excerpt(tt.content, tt.query)); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
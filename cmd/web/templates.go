@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/cedrickchee/snippetbox/pkg/diff"
 	"github.com/cedrickchee/snippetbox/pkg/forms"
 	"github.com/cedrickchee/snippetbox/pkg/models"
 )
@@ -21,6 +22,29 @@ type templateData struct {
 	Flash             string
 	AuthenticatedUser *models.User
 	CSRFToken         string
+	Revisions         []*models.Revision
+	FromRevision      *models.Revision
+	ToRevision        *models.Revision
+	Diff              []diff.Hunk
+	ShowSource        bool
+	Tag               string
+	Query             string
+	PrevPage          int // zero if there is no previous page
+	NextPage          int // zero if there is no next page
+	PrevCursor        string
+	NextCursor        string
+	PageSize          int
+	PagePath          string
+	User              *models.User
+	Starred           bool
+	Window            string
+	Comments          []*models.Comment
+	Comment           *models.Comment
+	Files             []*models.File
+	Stats             *viewStats
+	Collection        *models.Collection
+	Collections       []*models.Collection
+	RetentionDays     int // how long snippets are kept in the trash
 }
 
 // Create a humanDate function which returns a nicely formatted string
@@ -35,11 +59,41 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// commentData returns the template data for rendering a single comment on
+// the snippet page described by td.
+func commentData(td *templateData, c *models.Comment) *templateData {
+	return &templateData{
+		Comment:           c,
+		Snippet:           td.Snippet,
+		AuthenticatedUser: td.AuthenticatedUser,
+		CSRFToken:         td.CSRFToken,
+	}
+}
+
+// isLast reports whether i is the index of the last of n items.
+func isLast(i, n int) bool {
+	return i == n-1
+}
+
 // Initialize a template.FuncMap object and store it in a global variable. This is
 // essentially a string-keyed map which acts as a lookup between the names of our
 // custom template functions and the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":     humanDate,
+	"highlight":     highlight,
+	"languages":     languageOptions,
+	"languageLabel": languageLabel,
+	"markdown":      markdown,
+	"excerpt":       excerpt,
+	"commentData":   commentData,
+	"lineAnchor":    lineAnchor,
+	"snippetFiles":  snippetFiles,
+	"hasMarkdown":   hasMarkdown,
+	"fileField":     fileField,
+	"highlightFile": highlightFile,
+	"envelope":      formatEnvelope,
+	"percent":       percent,
+	"isLast":        isLast,
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
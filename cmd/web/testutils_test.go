@@ -56,7 +56,12 @@ func newTestApplication(t *testing.T) *application {
 		infoLog:       log.New(ioutil.Discard, "", 0),
 		templateCache: templateCache,
 		session:       session,
+		unlockLimiter: newLimiter(maxUnlockFailures, unlockWindow),
 		snippets:      &mock.SnippetModel{},
+		comments:      &mock.CommentModel{},
+		collections:   &mock.CollectionModel{},
+		views:         &mock.ViewModel{},
+		viewCounter:   newViewCounter(log.New(ioutil.Discard, "", 0), &mock.ViewModel{}, time.Minute),
 		users:         &mock.UserModel{},
 	}
 }
@@ -129,3 +134,23 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	// Return the response status, headers and body.
 	return rs.StatusCode, rs.Header, body
 }
+
+// login method signs the test server's client in as the mock user with the
+// given email address, so that subsequent requests carry an authenticated
+// session cookie. It returns a CSRF token which is valid for that session.
+func (ts *testServer) login(t *testing.T, email string) string {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", csrfToken)
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login as %s: want %d; got %d", email, http.StatusSeeOther, code)
+	}
+
+	return csrfToken
+}
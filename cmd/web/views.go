@@ -0,0 +1,197 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// viewCounter counts up views of snippets in memory, and periodically adds
+// the counts to the daily totals in the database, so that a popular snippet
+// costs a write every interval rather than one for every view. Counts not yet
+// flushed are lost if the server dies, which is fine for a rough count.
+type viewCounter struct {
+	errorLog *log.Logger
+	views    interface {
+		Add([]*models.ViewCount) error
+	}
+	interval time.Duration
+	// now decides which day a view is counted against, so a test can put
+	// views on days of its choosing.
+	now func() time.Time
+
+	mu     sync.Mutex
+	counts map[models.ViewCount]int
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newViewCounter returns a viewCounter which adds its counts to views.
+func newViewCounter(errorLog *log.Logger, views interface {
+	Add([]*models.ViewCount) error
+}, interval time.Duration) *viewCounter {
+	return &viewCounter{
+		errorLog: errorLog,
+		views:    views,
+		interval: interval,
+		now:      time.Now,
+		counts:   map[models.ViewCount]int{},
+	}
+}
+
+// record counts a view of a snippet by a visitor who came from domain.
+func (vc *viewCounter) record(snippetID int, domain string) {
+	day := vc.now().UTC().Truncate(24 * time.Hour)
+
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	// The counts are keyed by everything but the number of views.
+	vc.counts[models.ViewCount{SnippetID: snippetID, Day: day, Domain: domain}]++
+}
+
+// flush adds everything counted so far to the database. If that fails the
+// counts are dropped rather than kept, so that a database outage can't make
+// them grow without bound.
+func (vc *viewCounter) flush() {
+	vc.mu.Lock()
+	counts := vc.counts
+	vc.counts = map[models.ViewCount]int{}
+	vc.mu.Unlock()
+
+	if len(counts) == 0 {
+		return
+	}
+
+	batch := make([]*models.ViewCount, 0, len(counts))
+	for key, views := range counts {
+		c := key
+		c.Views = views
+		batch = append(batch, &c)
+	}
+
+	if err := vc.views.Add(batch); err != nil {
+		vc.errorLog.Printf("views: %s", err)
+	}
+}
+
+// start flushes the counts in a background goroutine every interval until
+// stop is called.
+func (vc *viewCounter) start() {
+	vc.quit = make(chan struct{})
+	vc.wg.Add(1)
+	go func() {
+		defer vc.wg.Done()
+
+		ticker := time.NewTicker(vc.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				vc.flush()
+			case <-vc.quit:
+				vc.flush()
+				return
+			}
+		}
+	}()
+}
+
+// stop tells the background goroutine to finish, which it does after one
+// last flush, and waits until it has. Anything recorded after that last flush
+// is never saved, so stop must only be called once nothing more will be
+// recorded, which for the server is once its Shutdown has returned.
+func (vc *viewCounter) stop() {
+	close(vc.quit)
+	vc.wg.Wait()
+}
+
+// botRX matches the User-Agent of crawlers, link previewers and command line
+// tools, none of which are people reading a snippet.
+var botRX = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|preview|facebookexternalhit|embedly|headless|curl|wget|python|go-http-client|java/|okhttp|libwww`)
+
+// isBot reports whether a request looks like it came from a bot rather than
+// a browser. A request without a User-Agent at all is assumed to be a bot.
+func isBot(r *http.Request) bool {
+	ua := r.UserAgent()
+	return ua == "" || botRX.MatchString(ua)
+}
+
+// referrerDomain returns the domain of the site a request was referred from,
+// without any 'www.' in front. It's empty if there is no referrer, or it's
+// this site, since moving between our own pages isn't an interesting source
+// of visitors.
+func referrerDomain(r *http.Request) string {
+	u, err := url.Parse(r.Referer())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	domain := strings.ToLower(u.Hostname())
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	if domain == strings.ToLower(host) {
+		return ""
+	}
+
+	domain = strings.TrimPrefix(domain, "www.")
+	if len(domain) > 255 {
+		return ""
+	}
+	return domain
+}
+
+// statsDays is the number of days, up to and including today, covered by a
+// snippet's stats page.
+const statsDays = 30
+
+// viewStats is what's shown on a snippet's stats page.
+type viewStats struct {
+	Days      int                  // the number of days covered by Daily
+	Daily     []*models.DailyViews // newest first, including days without views
+	Referrers []*models.ReferrerViews
+	Recent    int // the views over the Days
+	Max       int // the most views on any one of the Days
+	Total     int // the views ever
+}
+
+// newViewStats returns the stats for the days from since up to and including
+// today, given the days with views on them. The days without views are
+// filled in with zeroes.
+func newViewStats(daily []*models.DailyViews, referrers []*models.ReferrerViews, total int, since, today time.Time) *viewStats {
+	views := map[string]int{}
+	for _, d := range daily {
+		views[d.Day.UTC().Format("2006-01-02")] = d.Views
+	}
+
+	st := &viewStats{Referrers: referrers, Total: total}
+	for day := today; !day.Before(since); day = day.AddDate(0, 0, -1) {
+		n := views[day.Format("2006-01-02")]
+		st.Daily = append(st.Daily, &models.DailyViews{Day: day, Views: n})
+		st.Recent += n
+		if n > st.Max {
+			st.Max = n
+		}
+	}
+	st.Days = len(st.Daily)
+	return st
+}
+
+// percent returns n as a whole percentage of max, for drawing bars.
+func percent(n, max int) int {
+	if max == 0 {
+		return 0
+	}
+	return n * 100 / max
+}
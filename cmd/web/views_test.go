@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// fakeViewAdder records the counts added to it by the view counter.
+type fakeViewAdder struct {
+	batches [][]*models.ViewCount
+	err     error
+}
+
+func (f *fakeViewAdder) Add(counts []*models.ViewCount) error {
+	f.batches = append(f.batches, counts)
+	return f.err
+}
+
+func TestViewCounterFlush(t *testing.T) {
+	now := time.Date(2020, 12, 17, 10, 0, 0, 0, time.UTC)
+	adder := &fakeViewAdder{}
+	vc := newViewCounter(log.New(ioutil.Discard, "", 0), adder, time.Minute)
+	vc.now = func() time.Time { return now }
+
+	vc.record(1, "")
+	vc.record(1, "")
+	vc.record(1, "reddit.com")
+	vc.record(2, "")
+	vc.flush()
+
+	if len(adder.batches) != 1 {
+		t.Fatalf("want 1 batch; got %d", len(adder.batches))
+	}
+	batch := adder.batches[0]
+	sort.Slice(batch, func(i, j int) bool {
+		if batch[i].SnippetID != batch[j].SnippetID {
+			return batch[i].SnippetID < batch[j].SnippetID
+		}
+		return batch[i].Domain < batch[j].Domain
+	})
+
+	day := time.Date(2020, 12, 17, 0, 0, 0, 0, time.UTC)
+	want := []*models.ViewCount{
+		{SnippetID: 1, Day: day, Views: 2},
+		{SnippetID: 1, Day: day, Domain: "reddit.com", Views: 1},
+		{SnippetID: 2, Day: day, Views: 1},
+	}
+	if !reflect.DeepEqual(batch, want) {
+		t.Errorf("want %v; got %v", want, batch)
+	}
+
+	// With nothing counted since, there's nothing to add.
+	vc.flush()
+	if len(adder.batches) != 1 {
+		t.Errorf("want 1 batch; got %d", len(adder.batches))
+	}
+}
+
+func TestViewCounterFlushError(t *testing.T) {
+	adder := &fakeViewAdder{err: errors.New("database is down")}
+	errorLog := new(bytes.Buffer)
+	vc := newViewCounter(log.New(errorLog, "", 0), adder, time.Minute)
+
+	vc.record(1, "")
+	vc.flush()
+
+	if !bytes.Contains(errorLog.Bytes(), []byte("database is down")) {
+		t.Errorf("want error log %q to contain the error", errorLog.String())
+	}
+
+	// The counts are dropped rather than tried again.
+	vc.flush()
+	if len(adder.batches) != 1 {
+		t.Errorf("want 1 batch; got %d", len(adder.batches))
+	}
+}
+
+func TestViewCounterStop(t *testing.T) {
+	adder := &fakeViewAdder{}
+	vc := newViewCounter(log.New(ioutil.Discard, "", 0), adder, time.Hour)
+	vc.start()
+	vc.record(1, "")
+	vc.stop()
+
+	// Stopping flushes whatever was counted, without waiting for the
+	// interval.
+	if len(adder.batches) != 1 || adder.batches[0][0].Views != 1 {
+		t.Errorf("want the view to be flushed on stop; got %v", adder.batches)
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      bool
+	}{
+		{"Firefox", "Mozilla/5.0 (X11; Linux x86_64; rv:84.0) Gecko/20100101 Firefox/84.0", false},
+		{"Safari", "Mozilla/5.0 (iPhone; CPU iPhone OS 14_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.0 Mobile/15E148 Safari/604.1", false},
+		{"Googlebot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"Slack preview", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"Facebook preview", "facebookexternalhit/1.1", true},
+		{"curl", "curl/7.68.0", true},
+		{"Go", "Go-http-client/1.1", true},
+		{"Headless Chrome", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/87.0.4280.88 Safari/537.36", true},
+		{"No User-Agent", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/snippet/aB3dE5gH7j", nil)
+			r.Header.Set("User-Agent", tt.userAgent)
+			if got := isBot(r); got != tt.want {
+				t.Errorf("want %t; got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReferrerDomain(t *testing.T) {
+	tests := []struct {
+		name     string
+		referrer string
+		want     string
+	}{
+		{"Other site", "https://news.ycombinator.com/item?id=1", "news.ycombinator.com"},
+		{"Leading www", "https://www.reddit.com/r/golang/", "reddit.com"},
+		{"Upper case", "https://Lobste.RS/", "lobste.rs"},
+		{"Port", "http://localhost:8080/", "localhost"},
+		{"This site", "https://snippetbox.example.com:4000/snippets", ""},
+		{"No referrer", "", ""},
+		{"Not a web page", "android-app://com.slack/", ""},
+		{"Invalid", "https://%zz/", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "https://snippetbox.example.com:4000/snippet/aB3dE5gH7j", nil)
+			r.Header.Set("Referer", tt.referrer)
+			if got := referrerDomain(r); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNewViewStats(t *testing.T) {
+	today := time.Date(2020, 12, 17, 0, 0, 0, 0, time.UTC)
+	since := today.AddDate(0, 0, -3)
+	daily := []*models.DailyViews{
+		{Day: today.AddDate(0, 0, -3), Views: 2},
+		{Day: today.AddDate(0, 0, -1), Views: 5},
+	}
+
+	st := newViewStats(daily, nil, 20, since, today)
+
+	want := []*models.DailyViews{
+		{Day: today, Views: 0},
+		{Day: today.AddDate(0, 0, -1), Views: 5},
+		{Day: today.AddDate(0, 0, -2), Views: 0},
+		{Day: today.AddDate(0, 0, -3), Views: 2},
+	}
+	if !reflect.DeepEqual(st.Daily, want) {
+		t.Errorf("want %v; got %v", want, st.Daily)
+	}
+	if st.Days != 4 || st.Recent != 7 || st.Max != 5 || st.Total != 20 {
+		t.Errorf("want 4 days, 7 recent, 5 max and 20 total; got %d, %d, %d and %d", st.Days, st.Recent, st.Max, st.Total)
+	}
+}
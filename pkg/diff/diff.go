@@ -0,0 +1,257 @@
+// Package diff computes line-based differences between two texts and groups
+// them into hunks, in the style of the unified diff format.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op describes what happened to a line when going from the old text to the
+// new one.
+type Op int
+
+// The possible operations on a line.
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// String returns the name of the operation. It's handy as a CSS class name.
+func (o Op) String() string {
+	switch o {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// Prefix returns the character which marks the operation at the start of a
+// line in unified diff output.
+func (o Op) Prefix() string {
+	switch o {
+	case Insert:
+		return "+"
+	case Delete:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+// Line is a single line of a diff. OldNum and NewNum hold the 1-based line
+// number of the line in the old and new text respectively, or zero if the
+// line doesn't appear on that side.
+type Line struct {
+	Op     Op
+	Text   string
+	OldNum int
+	NewNum int
+}
+
+// Hunk is a run of changed lines surrounded by some unchanged context.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Header returns the '@@ -a,b +c,d @@' range line for the hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// maxTableCells caps the size of the table Lines builds to find the longest
+// common subsequence, which takes memory and time proportional to the
+// product of the number of changed lines on each side. Beyond it the changed
+// lines are simply all deleted and inserted, which is still a correct diff,
+// if not the shortest one.
+const maxTableCells = 1 << 20
+
+// Lines returns every line of a and b, marked with whether it was kept,
+// deleted from a or inserted into b. The result is a shortest edit script
+// found using the longest common subsequence of lines, unless the texts are
+// too large and different for that to be affordable.
+func Lines(a, b string) []Line {
+	as, bs := split(a), split(b)
+
+	// Lines shared at the start and end of both texts are always part of the
+	// common subsequence, so trim them off before building the table. Most
+	// edits touch a small part of the text and this keeps the table small.
+	prefix := 0
+	for prefix < len(as) && prefix < len(bs) && as[prefix] == bs[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(as)-prefix && suffix < len(bs)-prefix &&
+		as[len(as)-1-suffix] == bs[len(bs)-1-suffix] {
+		suffix++
+	}
+	am, bm := as[prefix:len(as)-suffix], bs[prefix:len(bs)-suffix]
+
+	lines := make([]Line, 0, len(as)+len(bm))
+	oldNum, newNum := 0, 0
+	keep := func(text string) {
+		oldNum++
+		newNum++
+		lines = append(lines, Line{Op: Equal, Text: text, OldNum: oldNum, NewNum: newNum})
+	}
+	insert := func(text string) {
+		newNum++
+		lines = append(lines, Line{Op: Insert, Text: text, NewNum: newNum})
+	}
+	remove := func(text string) {
+		oldNum++
+		lines = append(lines, Line{Op: Delete, Text: text, OldNum: oldNum})
+	}
+
+	for _, text := range as[:prefix] {
+		keep(text)
+	}
+
+	if (len(am)+1)*(len(bm)+1) > maxTableCells {
+		for _, text := range am {
+			remove(text)
+		}
+		for _, text := range bm {
+			insert(text)
+		}
+		for _, text := range as[len(as)-suffix:] {
+			keep(text)
+		}
+		return lines
+	}
+
+	// lcs[i][j] holds the length of the longest common subsequence of am[i:]
+	// and bm[j:].
+	lcs := make([][]int, len(am)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bm)+1)
+	}
+	for i := len(am) - 1; i >= 0; i-- {
+		for j := len(bm) - 1; j >= 0; j-- {
+			if am[i] == bm[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(am) || j < len(bm) {
+		switch {
+		case i < len(am) && j < len(bm) && am[i] == bm[j]:
+			keep(am[i])
+			i++
+			j++
+		case j < len(bm) && (i == len(am) || lcs[i][j+1] > lcs[i+1][j]):
+			insert(bm[j])
+			j++
+		default:
+			remove(am[i])
+			i++
+		}
+	}
+	for _, text := range as[len(as)-suffix:] {
+		keep(text)
+	}
+
+	return lines
+}
+
+// Unified returns the differences between a and b as hunks, each with up to
+// context unchanged lines either side of the changes. Changes which are close
+// enough for their context to overlap share a hunk. If a and b have the same
+// lines then no hunks are returned.
+func Unified(a, b string, context int) []Hunk {
+	lines := Lines(a, b)
+
+	var hunks []Hunk
+	for start := 0; start < len(lines); {
+		// Find the next changed line. If there isn't one, we're done.
+		first := start
+		for first < len(lines) && lines[first].Op == Equal {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// Extend the hunk until we hit a run of more than 2*context unchanged
+		// lines, or the end of the diff.
+		last := first
+		for i := first; i < len(lines); i++ {
+			if lines[i].Op != Equal {
+				last = i
+			} else if i-last > 2*context {
+				break
+			}
+		}
+
+		from := first - context
+		if from < 0 {
+			from = 0
+		}
+		to := last + context + 1
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		hunks = append(hunks, newHunk(lines[:from], lines[from:to]))
+		start = to
+	}
+
+	return hunks
+}
+
+// newHunk builds a hunk from the given lines, working out its ranges from the
+// lines which come before it.
+func newHunk(before, lines []Line) Hunk {
+	h := Hunk{Lines: lines}
+	for _, l := range before {
+		if l.Op != Insert {
+			h.OldStart++
+		}
+		if l.Op != Delete {
+			h.NewStart++
+		}
+	}
+	for _, l := range lines {
+		if l.Op != Insert {
+			h.OldLines++
+		}
+		if l.Op != Delete {
+			h.NewLines++
+		}
+	}
+
+	// Ranges start at the first line of the hunk, except that an empty range
+	// names the line after which it would be.
+	if h.OldLines > 0 {
+		h.OldStart++
+	}
+	if h.NewLines > 0 {
+		h.NewStart++
+	}
+	return h
+}
+
+// split breaks text into lines, treating CRLF (which is what browsers submit
+// from a textarea) the same as LF. A trailing newline doesn't start an extra,
+// empty line.
+func split(text string) []string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
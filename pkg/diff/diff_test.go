@@ -0,0 +1,105 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []Line
+	}{
+		{
+			name: "Identical",
+			a:    "one\ntwo",
+			b:    "one\r\ntwo\r\n",
+			want: []Line{
+				{Equal, "one", 1, 1},
+				{Equal, "two", 2, 2},
+			},
+		},
+		{
+			name: "Changed line",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: []Line{
+				{Equal, "one", 1, 1},
+				{Delete, "two", 2, 0},
+				{Insert, "2", 0, 2},
+				{Equal, "three", 3, 3},
+			},
+		},
+		{
+			name: "From empty",
+			a:    "",
+			b:    "one",
+			want: []Line{
+				{Insert, "one", 0, 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.a, tt.b)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLinesTooLarge(t *testing.T) {
+	// Enough changed lines on both sides that the table would be over the
+	// limit, so they're all replaced rather than matched up.
+	n := 2000
+	a := "first\n" + strings.Repeat("a\nb\n", n/2) + "last"
+	b := "first\n" + strings.Repeat("b\na\n", n/2) + "last"
+
+	lines := Lines(a, b)
+
+	if len(lines) != 2*n+2 {
+		t.Fatalf("want %d lines; got %d", 2*n+2, len(lines))
+	}
+	if want := (Line{Equal, "first", 1, 1}); lines[0] != want {
+		t.Errorf("want %v; got %v", want, lines[0])
+	}
+	for i, l := range lines[1 : 2*n+1] {
+		want := Delete
+		if i >= n {
+			want = Insert
+		}
+		if l.Op != want {
+			t.Fatalf("want line %d to be %s; got %s", i+1, want, l.Op)
+		}
+	}
+	if want := (Line{Equal, "last", n + 2, n + 2}); lines[2*n+1] != want {
+		t.Errorf("want %v; got %v", want, lines[2*n+1])
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11"
+
+	hunks := Unified(a, b, 1)
+
+	var headers []string
+	for _, h := range hunks {
+		headers = append(headers, h.Header())
+	}
+
+	want := []string{"@@ -2,3 +2,3 @@", "@@ -10,1 +10,2 @@"}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("want %v; got %v", want, headers)
+	}
+
+	if hunks := Unified(a, a, 3); len(hunks) != 0 {
+		t.Errorf("want no hunks; got %v", hunks)
+	}
+}
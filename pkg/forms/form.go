@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 )
@@ -18,6 +19,16 @@ import (
 // The pattern is currently recommended by the W3C and WHATWG.
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// TagRX is regular expression for checking the format of a tag. Tags are made
+// of letters, digits and hyphens, start with a letter or digit, and are at
+// most 32 characters long.
+var TagRX = regexp.MustCompile("^[a-zA-Z0-9][a-zA-Z0-9-]{0,31}$")
+
+// FilenameRX is regular expression for checking the name of a file in a
+// snippet. Names are made of letters, digits, dots, underscores and hyphens,
+// can't start with two dots, and are at most 100 characters long.
+var FilenameRX = regexp.MustCompile("^\\.?[a-zA-Z0-9_-][a-zA-Z0-9._-]{0,98}$")
+
 // Form struct anonymously embeds a url.Values object (to hold the form data)
 // and an Errors field to hold any validation errors for the form data.
 type Form struct {
@@ -101,6 +112,54 @@ func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 	}
 }
 
+// IntBetween method checks that a specific field in the form is a whole
+// number between min and max inclusive. If the check fails then add the
+// appropriate message to the form errors.
+func (f *Form) IntBetween(field string, min, max int) {
+	value := f.Get(field)
+	if value == "" {
+		return
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		f.Errors.Add(field, fmt.Sprintf("This field must be a whole number between %d and %d", min, max))
+	}
+}
+
+// List method splits a specific field holding a comma-separated list into its
+// items, with surrounding whitespace trimmed and any empty items left out.
+func (f *Form) List(field string) []string {
+	items := []string{}
+	for _, item := range strings.Split(f.Get(field), ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// MaxItems method checks that a specific comma-separated list field contains
+// a maximum number of items. If the check fails then add the appropriate
+// message to the form errors.
+func (f *Form) MaxItems(field string, d int) {
+	if len(f.List(field)) > d {
+		f.Errors.Add(field, fmt.Sprintf("This field has too many items (maximum is %d)", d))
+	}
+}
+
+// ItemsMatchPattern method checks that every item in a specific
+// comma-separated list field matches a regular expression. If the check fails
+// then add the appropriate message to the form errors.
+func (f *Form) ItemsMatchPattern(field string, pattern *regexp.Regexp) {
+	for _, item := range f.List(field) {
+		if !pattern.MatchString(item) {
+			f.Errors.Add(field, fmt.Sprintf("This field contains an invalid item (%s)", item))
+			return
+		}
+	}
+}
+
 // Valid method returns true if there are no errors.
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
@@ -0,0 +1,94 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+var mockCollection = &models.Collection{
+	ID:         1,
+	ShortID:    "cOlLeCt10n",
+	UserID:     1,
+	Author:     "Alice",
+	Name:       "Haiku",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Snippets:   2,
+}
+
+var mockPrivateCollection = &models.Collection{
+	ID:         2,
+	ShortID:    "pR1vAtEc0l",
+	UserID:     1,
+	Author:     "Alice",
+	Name:       "Drafts",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+}
+
+// CollectionModel is a mock of the collection store.
+type CollectionModel struct{}
+
+// Insert will pretend to add a new collection.
+func (m *CollectionModel) Insert(userID int, name, visibility string) (string, error) {
+	return "nEwC0lLect", nil
+}
+
+// Get will return a specific collection based on its short ID. Like the real
+// model, it returns a fresh copy each time.
+func (m *CollectionModel) Get(shortID string) (*models.Collection, error) {
+	for _, c := range []*models.Collection{mockCollection, mockPrivateCollection} {
+		if c.ShortID == shortID {
+			cp := *c
+			return &cp, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+// ByUser will return the mock collections, if they're Alice's.
+func (m *CollectionModel) ByUser(userID int, all bool) ([]*models.Collection, error) {
+	if userID != mockCollection.UserID {
+		return []*models.Collection{}, nil
+	}
+	if !all {
+		return []*models.Collection{mockCollection}, nil
+	}
+	return []*models.Collection{mockPrivateCollection, mockCollection}, nil
+}
+
+// ForSnippet will return Alice's collections, with mockSnippet in Haiku.
+func (m *CollectionModel) ForSnippet(userID, snippetID int) ([]*models.Collection, error) {
+	if userID != mockCollection.UserID {
+		return []*models.Collection{}, nil
+	}
+	drafts, haiku := *mockPrivateCollection, *mockCollection
+	haiku.Contains = snippetID == mockSnippet.ID
+	return []*models.Collection{&drafts, &haiku}, nil
+}
+
+// Update will pretend to change a collection.
+func (m *CollectionModel) Update(id int, name, visibility string) error {
+	return nil
+}
+
+// Delete will pretend to remove a collection.
+func (m *CollectionModel) Delete(id int) error {
+	return nil
+}
+
+// Add will pretend to put a snippet in a collection.
+func (m *CollectionModel) Add(collectionID, snippetID int) error {
+	return nil
+}
+
+// Remove will pretend to take a snippet out of a collection.
+func (m *CollectionModel) Remove(collectionID int, shortID string) error {
+	return nil
+}
+
+// Reorder will pretend to reorder the snippets in a collection.
+func (m *CollectionModel) Reorder(collectionID int, snippetIDs []int) error {
+	return nil
+}
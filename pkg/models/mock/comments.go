@@ -0,0 +1,93 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+var mockComment = &models.Comment{
+	ID:        1,
+	SnippetID: 1,
+	UserID:    1,
+	Author:    "Alice",
+	Body:      "Written on a rainy afternoon.",
+	Created:   time.Now(),
+}
+
+var mockReply = &models.Comment{
+	ID:        2,
+	SnippetID: 1,
+	UserID:    2,
+	Author:    "Carol",
+	ParentID:  1,
+	Body:      "Frogs jump in, splash!",
+	Created:   time.Now(),
+}
+
+var mockLineComment = &models.Comment{
+	ID:         3,
+	SnippetID:  1,
+	UserID:     2,
+	Author:     "Carol",
+	RevisionID: 1,
+	LineStart:  1,
+	LineEnd:    1,
+	Quote:      "An old pond...",
+	Outdated:   true,
+	Body:       "Which pond?",
+	Created:    time.Now(),
+}
+
+// CommentModel defines a type which wraps a sql.DB connection pool.
+type CommentModel struct{}
+
+// Insert will add a comment by the given user to a snippet, and return its
+// ID.
+func (m *CommentModel) Insert(snippetID, userID, parentID, lineStart, lineEnd int, body string) (int, error) {
+	switch parentID {
+	case 0, mockComment.ID, mockReply.ID, mockLineComment.ID:
+		return 4, nil
+	default:
+		return 0, models.ErrNoRecord
+	}
+}
+
+// Get will return a specific comment, without its replies.
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	switch id {
+	case mockComment.ID:
+		c := *mockComment
+		return &c, nil
+	case mockReply.ID:
+		c := *mockReply
+		return &c, nil
+	case mockLineComment.ID:
+		c := *mockLineComment
+		return &c, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+// ForSnippet will return the top level comments on a snippet, each with its
+// replies.
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	if snippetID != mockComment.SnippetID {
+		return []*models.Comment{}, nil
+	}
+
+	c := *mockComment
+	c.Replies = []*models.Comment{mockReply}
+	return []*models.Comment{&c, mockLineComment}, nil
+}
+
+// Update will change the body of an existing comment.
+func (m *CommentModel) Update(id int, body string) error {
+	return nil
+}
+
+// Delete will remove a comment, along with any replies to it.
+func (m *CommentModel) Delete(id int) error {
+	return nil
+}
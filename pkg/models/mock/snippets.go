@@ -1,38 +1,413 @@
 package mock
 
 import (
+	"bytes"
+	"strings"
 	"time"
 
 	"github.com/cedrickchee/snippetbox/pkg/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	ShortID:    "aB3dE5gH7j",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Language:   "text",
+	Visibility: models.VisibilityPublic,
+	Tags:       []string{"haiku", "poetry"},
+	Forks:      1,
+	Stars:      1,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+var mockPrivateSnippet = &models.Snippet{
+	ID:         4,
+	ShortID:    "pR1vAtExYz",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Config",
+	Content:    "secret: hunter2",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+var mockBurnSnippet = &models.Snippet{
+	ID:               5,
+	ShortID:          "bUrN4fTeRr",
+	UserID:           1,
+	Author:           "Alice",
+	Title:            "One time password",
+	Content:          "correct horse battery staple",
+	Visibility:       models.VisibilityUnlisted,
+	BurnAfterReading: true,
+	Created:          time.Now(),
+	Expires:          time.Now(),
+}
+
+var mockMarkdownSnippet = &models.Snippet{
+	ID:            6,
+	ShortID:       "mArKd0wNxy",
+	UserID:        1,
+	Author:        "Alice",
+	Title:         "Notes",
+	Content:       "# Haiku\n\nAn *old* silent pond...<script>alert(1)</script>",
+	Language:      "markdown",
+	Visibility:    models.VisibilityPublic,
+	ParentID:      1,
+	ParentShortID: "aB3dE5gH7j",
+	Created:       time.Now(),
+	Expires:       time.Now(),
+}
+
+var mockBundleSnippet = &models.Snippet{
+	ID:       7,
+	ShortID:  "bUnDlE7fIl",
+	UserID:   1,
+	Author:   "Alice",
+	Title:    "Web server",
+	Filename: "Dockerfile",
+	Content:  "FROM golang:1.13\n",
+	Language: "docker",
+	Files: []*models.File{
+		{Name: "run.sh", Language: "bash", Content: "#!/bin/sh\nexec ./web\n"},
+		{Name: "config.yaml", Language: "yaml", Content: "addr: \":4000\"\n"},
+	},
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+var mockProtectedSnippet = &models.Snippet{
+	ID:         8,
+	ShortID:    "l0cKeDsNip",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Staging database",
+	Content:    "postgres://staging",
+	Visibility: models.VisibilityUnlisted,
+	Protected:  true,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+// mockPassword is the password of mockProtectedSnippet.
+const mockPassword = "open sesame"
+
+var mockEncryptedSnippet = &models.Snippet{
+	ID:         9,
+	ShortID:    "eNcRyPt3dX",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Production keys",
+	Visibility: models.VisibilityUnlisted,
+	Encrypted:  true,
+	Ciphertext: append(bytes.Repeat([]byte{1}, 12), bytes.Repeat([]byte{2}, 20)...),
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+var mockTrashedSnippet = &models.Snippet{
+	ID:         3,
+	ShortID:    "tR4sHeDxYz",
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest...",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Deleted:    time.Now(),
+}
+
+var mockRevisions = []*models.Revision{
+	{
+		ID:        2,
+		SnippetID: 1,
+		UserID:    1,
+		Author:    "Alice",
+		Title:     "An old silent pond",
+		Content:   "An old silent pond...",
+		Created:   time.Now(),
+	},
+	{
+		ID:        1,
+		SnippetID: 1,
+		UserID:    1,
+		Author:    "Alice",
+		Title:     "An old pond",
+		Content:   "An old pond...",
+		Created:   time.Now(),
+	},
 }
 
 // SnippetModel defines a type which wraps a sql.DB connection pool.
 type SnippetModel struct{}
 
 // Insert will insert a new snippet into the database.
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
-	return 2, nil
+func (m *SnippetModel) Insert(s *models.Snippet) (string, error) {
+	return "nEwSn1pPet", nil
+}
+
+// Get will return a specific snippet based on its short ID. Like the real
+// model, it returns a fresh copy each time, so handlers can change it freely.
+func (m *SnippetModel) Get(shortID string) (*models.Snippet, error) {
+	for _, s := range []*models.Snippet{mockSnippet, mockPrivateSnippet, mockBurnSnippet, mockMarkdownSnippet, mockBundleSnippet, mockProtectedSnippet, mockEncryptedSnippet} {
+		if s.ShortID == shortID {
+			c := *s
+			return &c, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+// GetByID will return a specific snippet based on its integer id.
+func (m *SnippetModel) GetByID(id int) (*models.Snippet, error) {
+	for _, s := range []*models.Snippet{mockSnippet, mockPrivateSnippet, mockBurnSnippet, mockMarkdownSnippet, mockBundleSnippet, mockProtectedSnippet, mockEncryptedSnippet} {
+		if s.ID == id {
+			c := *s
+			return &c, nil
+		}
+	}
+	return nil, models.ErrNoRecord
 }
 
-// Get will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	switch id {
+// SetExpiry will change when an existing snippet expires.
+func (m *SnippetModel) SetExpiry(id int, expires time.Time) error {
+	return nil
+}
+
+// Burn will return a burn-after-reading snippet and delete it.
+func (m *SnippetModel) Burn(shortID string) (*models.Snippet, error) {
+	if shortID == mockBurnSnippet.ShortID {
+		return mockBurnSnippet, nil
+	}
+	return nil, models.ErrNoRecord
+}
+
+// Unlock checks the password for a password protected snippet.
+func (m *SnippetModel) Unlock(id int, password string) error {
+	if id != mockProtectedSnippet.ID {
+		return models.ErrNoRecord
+	}
+	if password != mockPassword {
+		return models.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// Update will save the title, content, language, visibility and tags of s to
+// the existing snippet with the same ID.
+func (m *SnippetModel) Update(s *models.Snippet, editorID int) error {
+	switch s.ID {
 	case 1:
-		return mockSnippet, nil
+		return nil
 	default:
-		return nil, models.ErrNoRecord
+		return models.ErrNoRecord
+	}
+}
+
+// Revisions will return every saved version of a snippet, newest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	revisions := []*models.Revision{}
+	for _, r := range mockRevisions {
+		if r.SnippetID == snippetID {
+			revisions = append(revisions, r)
+		}
+	}
+	return revisions, nil
+}
+
+// Revision will return a specific revision of a snippet.
+func (m *SnippetModel) Revision(snippetID, id int) (*models.Revision, error) {
+	for _, r := range mockRevisions {
+		if r.SnippetID == snippetID && r.ID == id {
+			return r, nil
+		}
 	}
+	return nil, models.ErrNoRecord
+}
+
+// Delete will move a snippet to the trash.
+func (m *SnippetModel) Delete(id int) error {
+	return nil
 }
 
-// Latest will return the 10 most recently created snippets.
+// Trash will return the snippets the given user has deleted.
+func (m *SnippetModel) Trash(userID int) ([]*models.Snippet, error) {
+	if userID == mockTrashedSnippet.UserID {
+		return []*models.Snippet{mockTrashedSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+// Restore will take a snippet belonging to the given user back out of the
+// trash.
+func (m *SnippetModel) Restore(userID int, shortID string) error {
+	if userID == mockTrashedSnippet.UserID && shortID == mockTrashedSnippet.ShortID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+// Purge will permanently remove a snippet belonging to the given user from
+// the trash.
+func (m *SnippetModel) Purge(userID int, shortID string) error {
+	if userID == mockTrashedSnippet.UserID && shortID == mockTrashedSnippet.ShortID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+// Latest will return the 10 most recently created public snippets. Snippets
+// which burn after reading are left out.
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+// Tagged will return up to limit public snippets with the given tag, newest
+// first, skipping the first offset of them.
+func (m *SnippetModel) Tagged(tag string, limit, offset int) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+	for _, s := range []*models.Snippet{mockSnippet, mockMarkdownSnippet} {
+		for _, t := range s.Tags {
+			if t == tag {
+				snippets = append(snippets, s)
+			}
+		}
+	}
+
+	if offset >= len(snippets) {
+		return []*models.Snippet{}, nil
+	}
+	snippets = snippets[offset:]
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+	return snippets, nil
+}
+
+// Search will return the given page of public snippets whose title or
+// content contain any of the words in the query, along with whether there
+// are any more pages after it.
+func (m *SnippetModel) Search(query string, page int) ([]*models.Snippet, bool, error) {
+	snippets := []*models.Snippet{}
+	for _, s := range []*models.Snippet{mockSnippet, mockMarkdownSnippet} {
+		text := strings.ToLower(s.Title + " " + s.Content)
+		for _, word := range strings.Fields(strings.ToLower(query)) {
+			if strings.Contains(text, word) {
+				snippets = append(snippets, s)
+				break
+			}
+		}
+	}
+
+	offset := (page - 1) * models.SearchPageSize
+	if offset >= len(snippets) {
+		return []*models.Snippet{}, false, nil
+	}
+	snippets = snippets[offset:]
+	if len(snippets) > models.SearchPageSize {
+		return snippets[:models.SearchPageSize], true, nil
+	}
+	return snippets, false, nil
+}
+
+// List will return up to limit public snippets, newest first, continuing
+// from the snippet at the cursor if there is one.
+func (m *SnippetModel) List(cursor *models.Cursor, newer bool, limit int) ([]*models.Snippet, error) {
+	// The mock snippets are listed newest first.
+	return page([]*models.Snippet{mockMarkdownSnippet, mockSnippet}, cursor, newer, limit), nil
+}
+
+// ByUser is like List, but only for the snippets owned by the given user. If
+// all is true their unlisted, private and burn-after-reading snippets are
+// included too.
+func (m *SnippetModel) ByUser(userID int, all bool, cursor *models.Cursor, newer bool, limit int) ([]*models.Snippet, error) {
+	if userID != mockSnippet.UserID {
+		return []*models.Snippet{}, nil
+	}
+
+	snippets := []*models.Snippet{mockMarkdownSnippet, mockSnippet}
+	if all {
+		snippets = []*models.Snippet{mockMarkdownSnippet, mockBurnSnippet, mockPrivateSnippet, mockSnippet}
+	}
+	return page(snippets, cursor, newer, limit), nil
+}
+
+// page returns up to limit of the given snippets, continuing from the
+// snippet at the cursor if there is one.
+func page(all []*models.Snippet, cursor *models.Cursor, newer bool, limit int) []*models.Snippet {
+	snippets := all
+	if cursor != nil {
+		snippets = []*models.Snippet{}
+		for i, s := range all {
+			if s.ID == cursor.ID {
+				if newer {
+					snippets = all[:i]
+				} else {
+					snippets = all[i+1:]
+				}
+				break
+			}
+		}
+	}
+
+	if len(snippets) > limit {
+		if newer {
+			snippets = snippets[len(snippets)-limit:]
+		} else {
+			snippets = snippets[:limit]
+		}
+	}
+	return snippets
+}
+
+// Star will record that the given user has starred a snippet.
+func (m *SnippetModel) Star(userID, snippetID int) error {
+	return nil
+}
+
+// Unstar will take away the given user's star from a snippet.
+func (m *SnippetModel) Unstar(userID, snippetID int) error {
+	return nil
+}
+
+// Starred reports whether the given user has starred a snippet. In the mock,
+// Carol has starred Alice's haiku.
+func (m *SnippetModel) Starred(userID, snippetID int) (bool, error) {
+	return userID == mockOtherUser.ID && snippetID == mockSnippet.ID, nil
+}
+
+// StarredBy is like List, but for the snippets the given user has starred.
+func (m *SnippetModel) StarredBy(userID int, cursor *models.Cursor, newer bool, limit int) ([]*models.Snippet, error) {
+	if userID != mockOtherUser.ID {
+		return []*models.Snippet{}, nil
+	}
+	return page([]*models.Snippet{mockSnippet}, cursor, newer, limit), nil
+}
+
+// InCollection will return the snippets in mockCollection, with the unlisted
+// and private ones only for their owner.
+func (m *SnippetModel) InCollection(collectionID, userID int) ([]*models.Snippet, error) {
+	snippets := []*models.Snippet{}
+	if collectionID != 1 {
+		return snippets, nil
+	}
+	for _, s := range []*models.Snippet{mockSnippet, mockMarkdownSnippet, mockProtectedSnippet, mockPrivateSnippet} {
+		if s.Visibility == models.VisibilityPublic || s.UserID == userID {
+			snippets = append(snippets, s)
+		}
+	}
+	return snippets, nil
+}
+
+// Popular will return up to limit public snippets with the most stars given
+// since the given time, most starred first.
+func (m *SnippetModel) Popular(since time.Time, limit int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockSnippet}, nil
+}
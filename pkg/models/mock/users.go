@@ -13,6 +13,13 @@ var mockUser = &models.User{
 	Created: time.Now(),
 }
 
+var mockOtherUser = &models.User{
+	ID:      2,
+	Name:    "Carol",
+	Email:   "carol@foo.bar",
+	Created: time.Now(),
+}
+
 // UserModel is user model.
 type UserModel struct{}
 
@@ -32,6 +39,8 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	switch email {
 	case "alice@foo.bar":
 		return 1, nil
+	case "carol@foo.bar":
+		return 2, nil
 	default:
 		return 0, models.ErrInvalidCredentials
 	}
@@ -42,6 +51,8 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	switch id {
 	case 1:
 		return mockUser, nil
+	case 2:
+		return mockOtherUser, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
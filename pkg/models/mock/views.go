@@ -0,0 +1,43 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// ViewModel is a mock of the daily view totals.
+type ViewModel struct{}
+
+// Add will pretend to add counts of views to the daily totals.
+func (m *ViewModel) Add(counts []*models.ViewCount) error {
+	return nil
+}
+
+// Daily will return the views of mockSnippet yesterday and today.
+func (m *ViewModel) Daily(snippetID int, since time.Time) ([]*models.DailyViews, error) {
+	if snippetID != mockSnippet.ID {
+		return []*models.DailyViews{}, nil
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	return []*models.DailyViews{
+		{Day: today.AddDate(0, 0, -1), Views: 4},
+		{Day: today, Views: 2},
+	}, nil
+}
+
+// Referrers will return the domains visitors to mockSnippet came from.
+func (m *ViewModel) Referrers(snippetID int, since time.Time, limit int) ([]*models.ReferrerViews, error) {
+	if snippetID != mockSnippet.ID {
+		return []*models.ReferrerViews{}, nil
+	}
+	return []*models.ReferrerViews{{Domain: "news.ycombinator.com", Views: 3}}, nil
+}
+
+// Total will return the views of a snippet ever.
+func (m *ViewModel) Total(snippetID int) (int, error) {
+	if snippetID != mockSnippet.ID {
+		return 0, nil
+	}
+	return 10, nil
+}
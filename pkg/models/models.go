@@ -18,13 +18,132 @@ var (
 	ErrDuplicateEmail = errors.New("models: duplicate email")
 )
 
+// TrashRetentionDays is the number of days a deleted snippet is kept in the
+// trash, where its owner can still restore it, before it is purged for good.
+const TrashRetentionDays = 30
+
+// SearchPageSize is the number of snippets on each page of search results.
+const SearchPageSize = 10
+
+// The visibility levels a snippet can have. Public snippets are listed on the
+// home page, unlisted ones can be viewed by anyone who has the link, and
+// private ones can only be viewed by their owner. Collections have the same
+// levels, except that public collections are listed on their owner's profile.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
 // Snippet is ...
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
+	ID               int
+	ShortID          string
+	UserID           int
+	Author           string
+	Title            string
+	Filename         string // the name of the main file, empty if none was given
+	Content          string
+	Language         string  // empty to detect the language from the content
+	Files            []*File // any further files, after the main one
+	Visibility       string
+	BurnAfterReading bool   // deleted the first time it is viewed
+	Password         string // plain text, only used by Insert and never read back
+	Protected        bool   // true if a password is needed to view it
+	Encrypted        bool   // true if the content was encrypted in the browser
+	Ciphertext       []byte // the encrypted content, which the server can't read
+	Tags             []string
+	ParentID         int    // the snippet this was forked from, zero if none
+	ParentShortID    string // empty unless the parent is public
+	Forks            int
+	Stars            int
+	Created          time.Time
+	Expires          time.Time // zero if the snippet never expires
+	Deleted          time.Time
+}
+
+// File is one of the further files of a multi-file snippet. The snippet's
+// own Content and Language make up its main file.
+type File struct {
+	Name     string
+	Language string // empty to detect the language from the content
+	Content  string
+}
+
+// Revision is a saved version of a snippet. A new one is recorded every time
+// a snippet is created or edited.
+type Revision struct {
+	ID        int
+	SnippetID int
+	UserID    int
+	Author    string
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// Comment is a comment on a snippet. Top level comments can have replies,
+// but replies can't have replies of their own. A top level comment can also
+// be about a range of lines, in which case it stays attached to the revision
+// of the snippet it was written against.
+type Comment struct {
+	ID         int
+	SnippetID  int
+	UserID     int
+	Author     string
+	ParentID   int // the comment this is a reply to, zero if it's top level
+	RevisionID int // the revision the lines are from, zero if there are none
+	LineStart  int // zero unless the comment is about a range of lines
+	LineEnd    int
+	Quote      string // the lines as they were in the revision
+	Outdated   bool   // true if the snippet has been edited since
+	Body       string
+	Created    time.Time
+	Edited     time.Time // zero if the comment has never been edited
+	Replies    []*Comment
+}
+
+// Collection is a named, ordered set of snippets kept by a user.
+type Collection struct {
+	ID         int
+	ShortID    string
+	UserID     int
+	Author     string
+	Name       string
+	Visibility string
+	Created    time.Time
+	Snippets   int  // the number of snippets in it which its owner can see
+	Contains   bool // whether it holds the snippet asked about by ForSnippet
+}
+
+// ViewCount is a number of views of a snippet on a day by visitors who came
+// from the same domain. Views are counted up this way before they're saved.
+type ViewCount struct {
+	SnippetID int
+	Day       time.Time
+	Domain    string // empty unless the visitor followed a link from another site
+	Views     int
+}
+
+// DailyViews is the number of views of a snippet on a day.
+type DailyViews struct {
+	Day   time.Time
+	Views int
+}
+
+// ReferrerViews is the number of views of a snippet by visitors who followed
+// a link from a domain.
+type ReferrerViews struct {
+	Domain string
+	Views  int
+}
+
+// Cursor marks a snippet's position in a listing of snippets ordered by
+// creation time. The ID breaks ties between snippets created at the same
+// moment.
+type Cursor struct {
 	Created time.Time
-	Expires time.Time
+	ID      int
 }
 
 // User is ...
@@ -0,0 +1,232 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// CollectionModel defines a type which wraps a sql.DB connection pool.
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+// collectionColumns is the list of columns selected for a collection, joined
+// with its owner as 'FROM collections c INNER JOIN users u ON u.id =
+// c.user_id'. The snippets counted are the ones the owner sees listed by
+// SnippetModel.InCollection, so snippets which are in the trash or have
+// expired, and other people's which aren't public, aren't counted.
+const collectionColumns = `c.id, c.short_id, c.user_id, u.name, c.name, c.visibility, c.created,
+	(SELECT COUNT(*) FROM collection_snippets cs INNER JOIN snippets s ON s.id = cs.snippet_id
+	WHERE cs.collection_id = c.id AND s.deleted IS NULL AND ` + notExpired + `
+	AND (s.visibility = '` + models.VisibilityPublic + `' OR s.user_id = c.user_id))`
+
+// collectionFields returns pointers to the fields of c which are scanned
+// from the collectionColumns.
+func collectionFields(c *models.Collection) []interface{} {
+	return []interface{}{&c.ID, &c.ShortID, &c.UserID, &c.Author, &c.Name, &c.Visibility, &c.Created, &c.Snippets}
+}
+
+// Insert will add a new, empty collection owned by the given user, and
+// return its short ID.
+func (m *CollectionModel) Insert(userID int, name, visibility string) (string, error) {
+	stmt := `INSERT INTO collections (short_id, user_id, name, visibility, created)
+	VALUES (?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	// As with snippets, a clash of short IDs is retried with a fresh one.
+	for attempt := 0; ; attempt++ {
+		shortID, err := models.NewShortID()
+		if err != nil {
+			return "", err
+		}
+
+		_, err = m.DB.Exec(stmt, shortID, userID, name, visibility)
+		if err == nil {
+			return shortID, nil
+		}
+		if attempt == 2 || !isDuplicate(err, "collections_uc_short_id") {
+			return "", err
+		}
+	}
+}
+
+// Get will return a specific collection based on its short ID.
+func (m *CollectionModel) Get(shortID string) (*models.Collection, error) {
+	stmt := `SELECT ` + collectionColumns + `
+	FROM collections c INNER JOIN users u ON u.id = c.user_id
+	WHERE c.short_id = ?`
+
+	c := &models.Collection{}
+	err := m.DB.QueryRow(stmt, shortID).Scan(collectionFields(c)...)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ByUser will return the collections owned by the given user, by name. If
+// all is true their unlisted and private collections are included too.
+func (m *CollectionModel) ByUser(userID int, all bool) ([]*models.Collection, error) {
+	stmt := `SELECT ` + collectionColumns + `
+	FROM collections c INNER JOIN users u ON u.id = c.user_id
+	WHERE c.user_id = ?`
+	args := []interface{}{userID}
+	if !all {
+		stmt += ` AND c.visibility = ?`
+		args = append(args, models.VisibilityPublic)
+	}
+	stmt += ` ORDER BY c.name, c.id`
+
+	return m.list(stmt, args...)
+}
+
+// ForSnippet will return all the collections owned by the given user, by
+// name, each with Contains set if the snippet is in it.
+func (m *CollectionModel) ForSnippet(userID, snippetID int) ([]*models.Collection, error) {
+	stmt := `SELECT ` + collectionColumns + `,
+	EXISTS(SELECT 1 FROM collection_snippets cs WHERE cs.collection_id = c.id AND cs.snippet_id = ?)
+	FROM collections c INNER JOIN users u ON u.id = c.user_id
+	WHERE c.user_id = ? ORDER BY c.name, c.id`
+
+	rows, err := m.DB.Query(stmt, snippetID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*models.Collection{}
+	for rows.Next() {
+		c := &models.Collection{}
+		err := rows.Scan(append(collectionFields(c), &c.Contains)...)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// list will return the collections selected by stmt, which must select the
+// collectionColumns.
+func (m *CollectionModel) list(stmt string, args ...interface{}) ([]*models.Collection, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*models.Collection{}
+	for rows.Next() {
+		c := &models.Collection{}
+		err := rows.Scan(collectionFields(c)...)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// Update will change the name and visibility of an existing collection.
+func (m *CollectionModel) Update(id int, name, visibility string) error {
+	stmt := `UPDATE collections SET name = ?, visibility = ? WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, name, visibility, id)
+	return err
+}
+
+// Delete will remove a collection. The snippets in it are left alone.
+func (m *CollectionModel) Delete(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM collections WHERE id = ?`, id)
+	return err
+}
+
+// Add will put a snippet at the end of a collection. Adding a snippet which
+// is already in the collection does nothing.
+func (m *CollectionModel) Add(collectionID, snippetID int) error {
+	stmt := `INSERT IGNORE INTO collection_snippets (collection_id, snippet_id, position)
+	SELECT ?, ?, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = ?`
+
+	_, err := m.DB.Exec(stmt, collectionID, snippetID, collectionID)
+	return err
+}
+
+// Remove will take the snippet with the given short ID out of a collection,
+// if it's in it. The snippet is found however it's been left, so one which
+// has since been put in the trash or has expired can still be taken out.
+func (m *CollectionModel) Remove(collectionID int, shortID string) error {
+	stmt := `DELETE cs FROM collection_snippets cs INNER JOIN snippets s ON s.id = cs.snippet_id
+	WHERE cs.collection_id = ? AND s.short_id = ?`
+
+	_, err := m.DB.Exec(stmt, collectionID, shortID)
+	return err
+}
+
+// Reorder will put the snippets in a collection in the order given by their
+// IDs. Any snippets in the collection which aren't given keep their order
+// among themselves, after the ones which are, and any IDs of snippets which
+// aren't in the collection are ignored.
+func (m *CollectionModel) Reorder(collectionID int, snippetIDs []int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the collection's snippets while we work out the new order, so
+	// that a concurrent Add or Reorder can't get in between.
+	stmt := `SELECT snippet_id FROM collection_snippets
+	WHERE collection_id = ? ORDER BY position FOR UPDATE`
+	rows, err := tx.Query(stmt, collectionID)
+	if err != nil {
+		return err
+	}
+	var current []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current = append(current, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	member := map[int]bool{}
+	for _, id := range current {
+		member[id] = true
+	}
+	order := []int{}
+	for _, id := range append(append([]int{}, snippetIDs...), current...) {
+		if member[id] {
+			order = append(order, id)
+			member[id] = false
+		}
+	}
+
+	// Every snippet is renumbered from 1, so the positions stay small
+	// however often the collection is reordered.
+	stmt = `UPDATE collection_snippets SET position = ? WHERE collection_id = ? AND snippet_id = ?`
+	for i, id := range order {
+		_, err = tx.Exec(stmt, i+1, collectionID, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
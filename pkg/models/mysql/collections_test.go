@@ -0,0 +1,234 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// collectionSnippetIDs returns the IDs of the snippets in a collection which
+// the owner of the test snippets can view, in order.
+func collectionSnippetIDs(t *testing.T, m *SnippetModel, collectionID int) []int {
+	snippets, err := m.InCollection(collectionID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, s := range snippets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func TestCollectionModelReorder(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := CollectionModel{db}
+	snippets := SnippetModel{db}
+
+	// Add two more snippets to the seeded collection, which already holds
+	// snippet 1. Adding one twice doesn't move it.
+	var ids []int
+	for _, title := range []string{"O snail", "First autumn morning"} {
+		shortID, err := snippets.Insert(&models.Snippet{UserID: 1, Title: title, Content: title, Visibility: models.VisibilityPublic})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := snippets.Get(shortID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.ID)
+	}
+	for _, id := range []int{ids[0], ids[1], ids[0]} {
+		if err := m.Add(1, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		order []int
+		want  []int
+	}{
+		{"In order of adding", nil, []int{1, ids[0], ids[1]}},
+		{"Reversed", []int{ids[1], ids[0], 1}, []int{ids[1], ids[0], 1}},
+		{"Only some given", []int{1}, []int{1, ids[1], ids[0]}},
+		{"Not in the collection", []int{99, ids[0]}, []int{ids[0], 1, ids[1]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.order != nil {
+				if err := m.Reorder(1, tt.order); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := collectionSnippetIDs(t, &snippets, 1); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+
+	// Removing a snippet leaves the others in order.
+	if err := m.Remove(1, "aB3dE5gH7j"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := collectionSnippetIDs(t, &snippets, 1), []int{ids[0], ids[1]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want %v; got %v", want, got)
+	}
+}
+
+func TestCollectionModelForSnippet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := CollectionModel{db}
+
+	shortID, err := m.Insert(1, "Drafts", models.VisibilityPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collections, err := m.ForSnippet(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 2 {
+		t.Fatalf("want 2 collections; got %d", len(collections))
+	}
+
+	// Collections come by name, so Drafts is before Haiku, and only Haiku
+	// holds the seeded snippet.
+	if c := collections[0]; c.ShortID != shortID || c.Contains || c.Snippets != 0 {
+		t.Errorf("want empty Drafts without the snippet; got %+v", c)
+	}
+	if c := collections[1]; c.ShortID != "cOlLeCt10n" || !c.Contains || c.Snippets != 1 || c.Author != "Alice Jones" {
+		t.Errorf("want Haiku with the snippet; got %+v", c)
+	}
+
+	// Only the public one is listed for everybody else.
+	public, err := m.ByUser(1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(public) != 1 || public[0].ShortID != "cOlLeCt10n" {
+		t.Errorf("want only Haiku; got %v", public)
+	}
+}
+
+func TestSnippetModelInCollection(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := CollectionModel{db}
+	snippets := SnippetModel{db}
+
+	// Put an unlisted and a private snippet in the seeded collection, after
+	// the public one already in it.
+	ids := []int{1}
+	for _, visibility := range []string{models.VisibilityUnlisted, models.VisibilityPrivate} {
+		shortID, err := snippets.Insert(&models.Snippet{UserID: 1, Title: visibility, Content: visibility, Visibility: visibility})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := snippets.Get(shortID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Add(1, s.ID); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.ID)
+	}
+
+	tests := []struct {
+		name   string
+		userID int
+		want   []int
+	}{
+		{"Owner", 1, ids},
+		{"Other user", 2, []int{1}},
+		{"Anonymous", 0, []int{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := snippets.InCollection(1, tt.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotIDs := []int{}
+			for _, s := range got {
+				gotIDs = append(gotIDs, s.ID)
+			}
+			if !reflect.DeepEqual(gotIDs, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, gotIDs)
+			}
+		})
+	}
+}
+
+func TestCollectionModelCountAndRemove(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := CollectionModel{db}
+	snippets := SnippetModel{db}
+
+	// Someone else's unlisted snippet in the collection isn't listed to the
+	// owner, so it isn't counted either.
+	shortID, err := snippets.Insert(&models.Snippet{UserID: 2, Title: "Unlisted", Content: "Unlisted", Visibility: models.VisibilityUnlisted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := snippets.Get(shortID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add(1, s.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := m.Get("cOlLeCt10n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Snippets != 1 {
+		t.Errorf("want 1 snippet counted; got %d", c.Snippets)
+	}
+
+	// A snippet in the trash can still be taken out of the collection.
+	if err := snippets.Delete(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove(1, "aB3dE5gH7j"); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	err = db.QueryRow(`SELECT COUNT(*) FROM collection_snippets WHERE collection_id = 1 AND snippet_id = 1`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("want the trashed snippet removed from the collection")
+	}
+}
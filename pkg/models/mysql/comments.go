@@ -0,0 +1,164 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// CommentModel defines a type which wraps a sql.DB connection pool.
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// commentColumns is the list of columns selected for a comment, from the
+// commentTables.
+//
+// For a comment on a range of lines, the quote is cut out of the revision's
+// content by its line breaks, and the comment is outdated if there has been a
+// later revision of the snippet since.
+const commentColumns = `c.id, c.snippet_id, c.user_id, u.name, c.parent_id,
+	c.revision_id, c.line_start, c.line_end,
+	REPLACE(SUBSTRING_INDEX(SUBSTRING_INDEX(r.content, '\n', c.line_end), '\n', c.line_start - c.line_end - 1), '\r', ''),
+	COALESCE(c.revision_id <> (SELECT MAX(lr.id) FROM snippet_revisions lr WHERE lr.snippet_id = c.snippet_id), FALSE),
+	c.body, c.created, c.edited`
+
+// commentTables joins a comment with its author and, if it's about a range of
+// lines, the revision it was written against.
+const commentTables = `comments c INNER JOIN users u ON u.id = c.user_id
+	LEFT JOIN snippet_revisions r ON r.id = c.revision_id`
+
+// commentFields returns pointers to the fields of c which are scanned from
+// the commentColumns.
+func commentFields(c *models.Comment) []interface{} {
+	return []interface{}{&c.ID, &c.SnippetID, &c.UserID, &c.Author, nullInt{&c.ParentID},
+		nullInt{&c.RevisionID}, nullInt{&c.LineStart}, nullInt{&c.LineEnd},
+		nullString{&c.Quote}, &c.Outdated,
+		&c.Body, &c.Created, nullTime{&c.Edited}}
+}
+
+// Insert will add a comment by the given user to a snippet, and return its
+// ID. A non-zero parentID makes the comment a reply. Replies only go one
+// level deep, so a reply to a reply is added to the same top level comment
+// instead. If the parent isn't a comment on the same snippet,
+// models.ErrNoRecord is returned.
+//
+// A non-zero lineStart makes a top level comment about the lines from
+// lineStart to lineEnd of the snippet's latest revision. Replies follow the
+// comment they reply to, so their lines are ignored.
+func (m *CommentModel) Insert(snippetID, userID, parentID, lineStart, lineEnd int, body string) (int, error) {
+	revisionID := 0
+	if parentID != 0 {
+		lineStart, lineEnd = 0, 0
+	} else if lineStart != 0 {
+		stmt := `SELECT MAX(id) FROM snippet_revisions WHERE snippet_id = ?`
+		err := m.DB.QueryRow(stmt, snippetID).Scan(nullInt{&revisionID})
+		if err != nil {
+			return 0, err
+		}
+		if revisionID == 0 {
+			return 0, models.ErrNoRecord
+		}
+	}
+
+	if parentID != 0 {
+		var parentSnippetID int
+		var grandparentID int
+		stmt := `SELECT snippet_id, parent_id FROM comments WHERE id = ?`
+		err := m.DB.QueryRow(stmt, parentID).Scan(&parentSnippetID, nullInt{&grandparentID})
+		if err == sql.ErrNoRows || (err == nil && parentSnippetID != snippetID) {
+			return 0, models.ErrNoRecord
+		} else if err != nil {
+			return 0, err
+		}
+		if grandparentID != 0 {
+			parentID = grandparentID
+		}
+	}
+
+	stmt := `INSERT INTO comments (snippet_id, user_id, parent_id, revision_id, line_start, line_end, body, created)
+	VALUES (?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, snippetID, userID, intOrNull(parentID),
+		intOrNull(revisionID), intOrNull(lineStart), intOrNull(lineEnd), body)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Get will return a specific comment, without its replies.
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	stmt := `SELECT ` + commentColumns + `
+	FROM ` + commentTables + `
+	WHERE c.id = ?`
+
+	c := &models.Comment{}
+	err := m.DB.QueryRow(stmt, id).Scan(commentFields(c)...)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ForSnippet will return the top level comments on a snippet, oldest first,
+// each with its replies, also oldest first.
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	// Every comment is selected in one go. Ordering by ID puts each reply
+	// after the comment it replies to, so the parent is always already known
+	// when we come to a reply.
+	stmt := `SELECT ` + commentColumns + `
+	FROM ` + commentTables + `
+	WHERE c.snippet_id = ? ORDER BY c.id`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	byID := map[int]*models.Comment{}
+	for rows.Next() {
+		c := &models.Comment{}
+		err := rows.Scan(commentFields(c)...)
+		if err != nil {
+			return nil, err
+		}
+
+		if parent, ok := byID[c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		} else {
+			byID[c.ID] = c
+			comments = append(comments, c)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Update will change the body of an existing comment, and mark it as edited.
+func (m *CommentModel) Update(id int, body string) error {
+	stmt := `UPDATE comments SET body = ?, edited = UTC_TIMESTAMP() WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, body, id)
+	return err
+}
+
+// Delete will remove a comment, along with any replies to it.
+func (m *CommentModel) Delete(id int) error {
+	_, err := m.DB.Exec(`DELETE FROM comments WHERE id = ?`, id)
+	return err
+}
@@ -0,0 +1,129 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+func TestCommentModelForSnippet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	tests := []struct {
+		name         string
+		snippetID    int
+		wantComments []*models.Comment
+	}{
+		{
+			name:      "Comment with a reply",
+			snippetID: 1,
+			wantComments: []*models.Comment{
+				{
+					ID:        1,
+					SnippetID: 1,
+					UserID:    1,
+					Author:    "Alice Jones",
+					Body:      "Written on a rainy afternoon.",
+					Created:   time.Date(2019, 1, 1, 11, 0, 0, 0, time.UTC),
+					Replies: []*models.Comment{
+						{
+							ID:        2,
+							SnippetID: 1,
+							UserID:    1,
+							Author:    "Alice Jones",
+							ParentID:  1,
+							Body:      "It stopped raining later.",
+							Created:   time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC),
+						},
+					},
+				},
+				{
+					ID:         3,
+					SnippetID:  1,
+					UserID:     1,
+					Author:     "Alice Jones",
+					RevisionID: 1,
+					LineStart:  1,
+					LineEnd:    1,
+					Quote:      "An old silent pond...",
+					Body:       "Which pond?",
+					Created:    time.Date(2019, 1, 1, 13, 0, 0, 0, time.UTC),
+				},
+			},
+		},
+		{
+			name:         "No comments",
+			snippetID:    2,
+			wantComments: []*models.Comment{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, teardown := newTestDB(t)
+			defer teardown()
+
+			m := CommentModel{db}
+
+			comments, err := m.ForSnippet(tt.snippetID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(comments, tt.wantComments) {
+				t.Errorf("want %v; got %v", tt.wantComments, comments)
+			}
+		})
+	}
+}
+
+func TestCommentModelInsert(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := CommentModel{db}
+
+	// A reply to a reply is added to the top level comment instead.
+	id, err := m.Insert(1, 1, 2, 1, 1, "And then the sun came out.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ParentID != 1 {
+		t.Errorf("want parent %d; got %d", 1, c.ParentID)
+	}
+	// Replies follow the lines of the comment they reply to.
+	if c.LineStart != 0 || c.RevisionID != 0 {
+		t.Errorf("want no lines; got %d-%d of revision %d", c.LineStart, c.LineEnd, c.RevisionID)
+	}
+
+	// A comment on a range of lines is attached to the latest revision.
+	id, err = m.Insert(1, 1, 0, 1, 1, "Still raining.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err = m.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RevisionID != 1 || c.Quote != "An old silent pond..." || c.Outdated {
+		t.Errorf("want current comment on revision 1; got revision %d, quote %q, outdated %t", c.RevisionID, c.Quote, c.Outdated)
+	}
+
+	// A comment can't reply to a comment on another snippet.
+	_, err = m.Insert(2, 1, 1, 0, 0, "Wrong snippet.")
+	if err != models.ErrNoRecord {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+}
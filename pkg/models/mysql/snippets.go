@@ -2,8 +2,12 @@ package mysql
 
 import (
 	"database/sql"
+	"strings"
+	"time"
 
 	"github.com/cedrickchee/snippetbox/pkg/models"
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // SnippetModel defines a type which wraps a sql.DB connection pool.
@@ -11,48 +15,223 @@ type SnippetModel struct {
 	DB *sql.DB
 }
 
-// Insert will insert a new snippet into the database.
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
+// snippetColumns is the list of columns selected for a snippet, joined with
+// its owner as 'FROM snippets s INNER JOIN users u ON u.id = s.user_id'. The
+// order matches the fields returned by snippetFields. The ciphertext of an
+// encrypted snippet is left out, as only the snippet's own page needs it.
+const snippetColumns = `s.id, s.short_id, s.user_id, u.name, s.title, s.filename, s.content, s.language,
+	s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.ciphertext IS NOT NULL, s.created, s.expires, s.parent_id,
+	(SELECT COUNT(*) FROM stars sr WHERE sr.snippet_id = s.id)`
+
+// snippetFields returns pointers to the fields of s which are scanned from
+// the snippetColumns.
+func snippetFields(s *models.Snippet) []interface{} {
+	return []interface{}{&s.ID, &s.ShortID, &s.UserID, &s.Author, &s.Title, &s.Filename, &s.Content, &s.Language, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.Encrypted, &s.Created, nullTime{&s.Expires}, nullInt{&s.ParentID}, &s.Stars}
+}
+
+// notExpired is the condition on the aliased snippets table which matches
+// snippets that haven't expired yet. A NULL expiry means never.
+const notExpired = `(s.expires IS NULL OR s.expires > UTC_TIMESTAMP())`
+
+// nullTime scans a nullable DATETIME column into a time.Time, leaving it as
+// the zero time for NULL.
+type nullTime struct {
+	t *time.Time
+}
+
+// Scan implements the sql.Scanner interface.
+func (n nullTime) Scan(value interface{}) error {
+	var nt sql.NullTime
+	if err := nt.Scan(value); err != nil {
+		return err
+	}
+	*n.t = nt.Time
+	return nil
+}
+
+// nullInt scans a nullable INTEGER column into an int, leaving it as zero for
+// NULL.
+type nullInt struct {
+	n *int
+}
+
+// Scan implements the sql.Scanner interface.
+func (n nullInt) Scan(value interface{}) error {
+	var ni sql.NullInt64
+	if err := ni.Scan(value); err != nil {
+		return err
+	}
+	*n.n = int(ni.Int64)
+	return nil
+}
+
+// nullString scans a nullable string column into a string, leaving it empty
+// for NULL.
+type nullString struct {
+	s *string
+}
+
+// Scan implements the sql.Scanner interface.
+func (n nullString) Scan(value interface{}) error {
+	var ns sql.NullString
+	if err := ns.Scan(value); err != nil {
+		return err
+	}
+	*n.s = ns.String
+	return nil
+}
+
+// intOrNull returns n as a query argument, with zero becoming NULL.
+func intOrNull(n int) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// timeOrNull returns t as a query argument, with the zero time becoming NULL.
+func timeOrNull(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UTC()
+}
+
+// Insert will insert a new snippet into the database, along with its first
+// revision. The owner, title, files, ciphertext, visibility, burn after
+// reading flag, password, tags, expiry time and parent are taken from s; a
+// zero Expires means the snippet never expires, a nil Ciphertext that it
+// isn't encrypted, an empty Password that it isn't password protected, and a
+// zero ParentID that it isn't a fork. It returns the short ID of the new
+// snippet.
+func (m *SnippetModel) Insert(s *models.Snippet) (string, error) {
+	// Only a bcrypt hash of the password is stored, the same as for users.
+	var hashedPassword interface{}
+	if s.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(s.Password), 12)
+		if err != nil {
+			return "", err
+		}
+		hashedPassword = string(hash)
+	}
+
+	// The ciphertext is stored exactly as it was given. There's nothing else
+	// we can do with it.
+	var ciphertext interface{}
+	if s.Ciphertext != nil {
+		ciphertext = s.Ciphertext
+	}
+
+	// The snippet and its first revision are written in a transaction, so
+	// that we never end up with a snippet which has no history.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
 	// Write the SQL statement we want to execute. I've split it over two lines
 	// for readability (which is why it's surrounded with backquotes instead
 	// of normal double quotes).
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (short_id, user_id, title, filename, content, language, ciphertext,
+	visibility, burn_after_reading, hashed_password, created, expires, parent_id)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?, ?)`
 
-	// Use the Exec() method on the embedded connection pool to execute the
-	// statement. The first parameter is the SQL statement, followed by the
-	// title, content and expiry values for the placeholder parameters. This
-	// method returns a sql.Result object, which contains some basic
-	// information about what happened when the statement was executed.
-	result, err := m.DB.Exec(stmt, title, content, expires)
-	if err != nil {
-		return 0, err
+	// Short IDs are random, so there's a (very) small chance that the one we
+	// pick is already taken. If the insert trips over the unique constraint
+	// we simply try again with a fresh one.
+	var shortID string
+	var result sql.Result
+	for attempt := 0; ; attempt++ {
+		shortID, err = models.NewShortID()
+		if err != nil {
+			return "", err
+		}
+
+		// Use the Exec() method on the transaction to execute the statement.
+		// The first parameter is the SQL statement, followed by the short ID,
+		// owner, title, filename, content, language, ciphertext, visibility,
+		// burn, password, expiry and parent values for the placeholder
+		// parameters. This method returns a sql.Result object,
+		// which contains some basic information about what happened when the
+		// statement was executed.
+		result, err = tx.Exec(stmt, shortID, s.UserID, s.Title, s.Filename, s.Content, s.Language, ciphertext,
+			s.Visibility, s.BurnAfterReading, hashedPassword, timeOrNull(s.Expires), intOrNull(s.ParentID))
+		if err == nil {
+			break
+		}
+		if attempt == 2 || !isDuplicate(err, "snippets_uc_short_id") {
+			return "", err
+		}
 	}
 
 	// Use the LastInsertId() method on the result object to get the ID of our
 	// newly inserted record in the snippets table.
 	id, err := result.LastInsertId()
 	if err != nil {
-		return 0, err
+		return "", err
+	}
+
+	err = insertRevision(tx, int(id), s.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	err = setTags(tx, int(id), s.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	err = insertFiles(tx, int(id), s.Files)
+	if err != nil {
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", err
 	}
 
-	// The ID returned has the type int64, so we convert it to an int type
-	// before returning.
-	return int(id), nil
+	return shortID, nil
+}
+
+// isDuplicate reports whether err is a MySQL duplicate entry error for the
+// named unique key.
+func isDuplicate(err error, key string) bool {
+	mysqlErr, ok := err.(*mysql.MySQLError)
+	return ok && mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, key)
 }
 
-// Get will return a specific snippet based on its id.
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	// Write the SQL statement we want to execute. Again, I've split it over two
-	// lines for readability.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+// Get will return a specific snippet based on its short ID.
+func (m *SnippetModel) Get(shortID string) (*models.Snippet, error) {
+	return m.get("s.short_id = ?", shortID)
+}
+
+// GetByID will return a specific snippet based on its integer id. It's used
+// to redirect the sequential URLs from before snippets had short IDs.
+func (m *SnippetModel) GetByID(id int) (*models.Snippet, error) {
+	return m.get("s.id = ?", id)
+}
+
+// get will return the snippet matching the given condition on the snippets
+// table, as long as it hasn't expired or been deleted. Unlike the listings,
+// it also fills in the snippet's fork details.
+func (m *SnippetModel) get(cond string, arg interface{}) (*models.Snippet, error) {
+	// Write the SQL statement we want to execute. We join against the users
+	// table so that the name of the snippet's owner comes back with it. The
+	// short ID of the parent is only given out if the parent is public, so
+	// that forks don't leak the links to unlisted or private snippets.
+	stmt := `SELECT ` + snippetColumns + `, s.ciphertext,
+	(SELECT p.short_id FROM snippets p WHERE p.id = s.parent_id AND p.visibility = ?
+		AND p.deleted IS NULL AND (p.expires IS NULL OR p.expires > UTC_TIMESTAMP())),
+	(SELECT COUNT(*) FROM snippets f WHERE f.parent_id = s.id AND f.deleted IS NULL)
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND s.deleted IS NULL AND ` + cond
 
 	// Use the QueryRow() method on the connection pool to execute our
 	// SQL statement, passing in the untrusted id variable as the value for the
 	// placeholder parameter. This returns a pointer to a sql.Row object which
 	// holds the result from the database.
-	row := m.DB.QueryRow(stmt, id)
+	row := m.DB.QueryRow(stmt, models.VisibilityPublic, arg)
 
 	// Initialize a pointer to a new zeroed Snippet struct.
 	s := &models.Snippet{}
@@ -64,7 +243,7 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 	// columns returned by your statement. If the query returns no rows, then
 	// row.Scan() will return a sql.ErrNoRows error. We check for that and return
 	// our own models.ErrNoRecord error instead of a Snippet object.
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(append(snippetFields(s), &s.Ciphertext, nullString{&s.ParentShortID}, &s.Forks)...)
 	if err == sql.ErrNoRows {
 		// You might be wondering why we’re returning the models.ErrNoRecord
 		// error instead of sql.ErrNoRows directly. The reason is to help
@@ -76,20 +255,417 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 		return nil, err
 	}
 
+	err = loadTags(m.DB, []*models.Snippet{s})
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadFiles(m.DB, s)
+	if err != nil {
+		return nil, err
+	}
+
 	// If everything went OK then return the Snippet object.
 	return s, nil
 }
 
-// Latest will return the 10 most recently created snippets.
+// Burn will return a burn-after-reading snippet and delete it, in one go. If
+// two requests race to burn the same snippet only one of them gets it back;
+// the other gets models.ErrNoRecord, as if the snippet had never existed.
+func (m *SnippetModel) Burn(shortID string) (*models.Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// SELECT ... FOR UPDATE locks the row until the transaction ends, so a
+	// concurrent Burn of the same snippet blocks here until we've deleted
+	// it, and then finds nothing.
+	stmt := `SELECT ` + snippetColumns + `, s.ciphertext
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND s.deleted IS NULL
+	AND s.burn_after_reading = TRUE AND s.short_id = ?
+	FOR UPDATE`
+
+	s := &models.Snippet{}
+	err = tx.QueryRow(stmt, shortID).Scan(append(snippetFields(s), &s.Ciphertext)...)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = loadTags(tx, []*models.Snippet{s})
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadFiles(tx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// Burnt snippets skip the trash. Their revisions and files go with them,
+	// thanks to the ON DELETE CASCADE foreign keys.
+	_, err = tx.Exec(`DELETE FROM snippets WHERE id = ?`, s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Unlock checks the password for a password protected snippet. If it's wrong,
+// models.ErrInvalidCredentials is returned, and if the snippet doesn't exist
+// or has no password, models.ErrNoRecord.
+func (m *SnippetModel) Unlock(id int, password string) error {
+	var hashedPassword []byte
+	row := m.DB.QueryRow(`SELECT hashed_password FROM snippets
+	WHERE hashed_password IS NOT NULL AND id = ?`, id)
+	err := row.Scan(&hashedPassword)
+	if err == sql.ErrNoRows {
+		return models.ErrNoRecord
+	} else if err != nil {
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return models.ErrInvalidCredentials
+	}
+	return err
+}
+
+// Update will save the title, content, language, visibility and tags of s to
+// the existing snippet with the same ID, recording the result as a new
+// revision by the given editor. Revisions only record the main file, so a
+// snippet with further files is never updated; it stays as it was created.
+func (m *SnippetModel) Update(s *models.Snippet, editorID int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets s SET s.title = ?, s.content = ?, s.language = ?, s.visibility = ?
+	WHERE ` + notExpired + ` AND s.deleted IS NULL AND s.id = ?`
+
+	_, err = tx.Exec(stmt, s.Title, s.Content, s.Language, s.Visibility, s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(tx, s.ID, editorID)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, s.ID, s.Tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetExpiry will change when an existing snippet expires. A zero expires
+// means the snippet never expires.
+func (m *SnippetModel) SetExpiry(id int, expires time.Time) error {
+	stmt := `UPDATE snippets s SET s.expires = ?
+	WHERE ` + notExpired + ` AND s.deleted IS NULL AND s.id = ?`
+
+	_, err := m.DB.Exec(stmt, timeOrNull(expires), id)
+	return err
+}
+
+// insertRevision records the current title and content of a snippet as a new
+// revision by the given user.
+func insertRevision(tx *sql.Tx, snippetID, userID int) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+	SELECT id, ?, title, content, UTC_TIMESTAMP() FROM snippets WHERE id = ?`
+
+	_, err := tx.Exec(stmt, userID, snippetID)
+	return err
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// setTags replaces the tags of a snippet with the given ones, creating any
+// tags which don't exist yet.
+func setTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		// Setting id to LAST_INSERT_ID(id) on a duplicate makes
+		// LastInsertId() return the ID of the existing tag, so either way we
+		// get the tag's ID back without another query.
+		result, err := tx.Exec(`INSERT INTO tags (name) VALUES (?)
+		ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`, tag)
+		if err != nil {
+			return err
+		}
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO snippet_tags (snippet_id, tag_id) VALUES (?, ?)`, snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadTags fills in the tags of the given snippets, in alphabetical order,
+// using a single query for all of them.
+func loadTags(q querier, snippets []*models.Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.Snippet, len(snippets))
+	args := make([]interface{}, len(snippets))
+	for i, s := range snippets {
+		byID[s.ID] = s
+		args[i] = s.ID
+	}
+
+	stmt := `SELECT st.snippet_id, t.name
+	FROM snippet_tags st INNER JOIN tags t ON t.id = st.tag_id
+	WHERE st.snippet_id IN (?` + strings.Repeat(", ?", len(snippets)-1) + `)
+	ORDER BY t.name`
+
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snippetID int
+		var name string
+		if err := rows.Scan(&snippetID, &name); err != nil {
+			return err
+		}
+		s := byID[snippetID]
+		s.Tags = append(s.Tags, name)
+	}
+	return rows.Err()
+}
+
+// insertFiles saves the further files of a new snippet, in order.
+func insertFiles(tx *sql.Tx, snippetID int, files []*models.File) error {
+	stmt := `INSERT INTO snippet_files (snippet_id, position, name, language, content)
+	VALUES (?, ?, ?, ?, ?)`
+
+	for i, f := range files {
+		_, err := tx.Exec(stmt, snippetID, i+1, f.Name, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFiles fills in the further files of a snippet. Unlike the tags, files
+// are only loaded for a single snippet at a time, as the listings never show
+// them.
+func loadFiles(q querier, s *models.Snippet) error {
+	stmt := `SELECT name, language, content FROM snippet_files
+	WHERE snippet_id = ? ORDER BY position`
+
+	rows, err := q.Query(stmt, s.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		f := &models.File{}
+		if err := rows.Scan(&f.Name, &f.Language, &f.Content); err != nil {
+			return err
+		}
+		s.Files = append(s.Files, f)
+	}
+	return rows.Err()
+}
+
+// Revisions will return every saved version of a snippet, newest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.user_id, u.name, r.title, r.content, r.created
+	FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+	WHERE r.snippet_id = ? ORDER BY r.id DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		r := &models.Revision{}
+		err := rows.Scan(&r.ID, &r.SnippetID, &r.UserID, &r.Author, &r.Title, &r.Content, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Revision will return a specific revision of a snippet.
+func (m *SnippetModel) Revision(snippetID, id int) (*models.Revision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.user_id, u.name, r.title, r.content, r.created
+	FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+	WHERE r.snippet_id = ? AND r.id = ?`
+
+	r := &models.Revision{}
+	err := m.DB.QueryRow(stmt, snippetID, id).Scan(&r.ID, &r.SnippetID, &r.UserID, &r.Author, &r.Title, &r.Content, &r.Created)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Delete will move a snippet to the trash. The row is kept, so that its owner
+// can restore it until the retention window runs out.
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `UPDATE snippets SET deleted = UTC_TIMESTAMP()
+	WHERE deleted IS NULL AND id = ?`
+
+	_, err := m.DB.Exec(stmt, id)
+	return err
+}
+
+// Trash will return the snippets the given user has deleted which are still
+// within the retention window, most recently deleted first.
+func (m *SnippetModel) Trash(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `, s.deleted
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.user_id = ? AND s.deleted > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? DAY)
+	ORDER BY s.deleted DESC`
+
+	rows, err := m.DB.Query(stmt, userID, models.TrashRetentionDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err := rows.Scan(append(snippetFields(s), &s.Deleted)...)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Restore will take a snippet belonging to the given user back out of the
+// trash. If there is no such snippet in the trash, models.ErrNoRecord is
+// returned.
+func (m *SnippetModel) Restore(userID int, shortID string) error {
+	stmt := `UPDATE snippets SET deleted = NULL
+	WHERE user_id = ? AND short_id = ?
+	AND deleted > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? DAY)`
+
+	result, err := m.DB.Exec(stmt, userID, shortID, models.TrashRetentionDays)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(result)
+}
+
+// Purge will permanently remove a snippet belonging to the given user from
+// the trash. If there is no such snippet in the trash, models.ErrNoRecord is
+// returned.
+func (m *SnippetModel) Purge(userID int, shortID string) error {
+	stmt := `DELETE FROM snippets WHERE user_id = ? AND short_id = ? AND deleted IS NOT NULL`
+
+	result, err := m.DB.Exec(stmt, userID, shortID)
+	if err != nil {
+		return err
+	}
+	return requireRowsAffected(result)
+}
+
+// PurgeExpired will permanently remove up to limit snippets which had expired
+// by the given time, or which had been in the trash for longer than the
+// retention window by then. It returns the number of snippets removed, so
+// callers can keep going in batches until that's less than limit.
+func (m *SnippetModel) PurgeExpired(now time.Time, limit int) (int, error) {
+	stmt := `DELETE FROM snippets
+	WHERE (expires IS NOT NULL AND expires <= ?) OR (deleted IS NOT NULL AND deleted <= ?)
+	LIMIT ?`
+
+	trashCutoff := now.AddDate(0, 0, -models.TrashRetentionDays)
+	result, err := m.DB.Exec(stmt, now.UTC(), trashCutoff.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// requireRowsAffected returns models.ErrNoRecord if the statement behind
+// result didn't touch any rows.
+func requireRowsAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Latest will return the 10 most recently created public snippets. Snippets
+// which burn after reading are left out, so that a passer-by doesn't burn
+// them by accident.
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	// Write the SQL statement we want to execute.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND s.deleted IS NULL AND s.visibility = ?
+	AND s.burn_after_reading = FALSE
+	ORDER BY s.created DESC LIMIT 10`
 
 	// Use the Query() method on the connection pool to execute our
 	// SQL statement. This returns a sql.Rows resultset containing the result of
 	// our query.
-	rows, err := m.DB.Query(stmt)
+	rows, err := m.DB.Query(stmt, models.VisibilityPublic)
 	if err != nil {
 		return nil, err
 	}
@@ -118,7 +694,7 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 		// must be pointers to the place you want to copy the data into, and the
 		// number of arguments must be exactly the same as the number of
 		// columns returned by your statement.
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := rows.Scan(snippetFields(s)...)
 		if err != nil {
 			return nil, err
 		}
@@ -134,6 +710,229 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 		return nil, err
 	}
 
+	// Fetch the tags of all the snippets in one go.
+	if err = loadTags(m.DB, snippets); err != nil {
+		return nil, err
+	}
+
 	// If everything went OK then return the Snippets slice.
 	return snippets, nil
 }
+
+// List will return up to limit public snippets, newest first. If cursor is
+// nil the listing starts from the newest snippet. Otherwise it continues from
+// the snippet at the cursor: with the snippets just older than it, or if
+// newer is true, the ones just newer than it. Like Latest, snippets which
+// burn after reading are left out.
+func (m *SnippetModel) List(cursor *models.Cursor, newer bool, limit int) ([]*models.Snippet, error) {
+	return m.page(`s.visibility = ? AND s.burn_after_reading = FALSE`,
+		[]interface{}{models.VisibilityPublic}, cursor, newer, limit)
+}
+
+// ByUser is like List, but only for the snippets owned by the given user. If
+// all is true their unlisted, private and burn-after-reading snippets are
+// included too.
+func (m *SnippetModel) ByUser(userID int, all bool, cursor *models.Cursor, newer bool, limit int) ([]*models.Snippet, error) {
+	cond := `s.user_id = ?`
+	args := []interface{}{userID}
+	if !all {
+		cond += ` AND s.visibility = ? AND s.burn_after_reading = FALSE`
+		args = append(args, models.VisibilityPublic)
+	}
+
+	return m.page(cond, args, cursor, newer, limit)
+}
+
+// page will return a page of the snippets matching the given condition on
+// the snippets table, as described for List.
+func (m *SnippetModel) page(cond string, args []interface{}, cursor *models.Cursor, newer bool, limit int) ([]*models.Snippet, error) {
+	// We page with a cursor on (created, id) rather than an OFFSET, because
+	// MySQL has to read and throw away every row it skips over with an
+	// OFFSET, so deep pages get slower and slower. InnoDB secondary indexes
+	// carry the primary key, so the indexes on created and (user_id,
+	// created) cover (created, id) and each page is a short range scan
+	// wherever it is.
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND s.deleted IS NULL AND ` + cond
+
+	order := `ORDER BY s.created DESC, s.id DESC`
+	if cursor != nil {
+		created := cursor.Created.UTC()
+		if newer {
+			// To get the snippets just newer than the cursor we have to
+			// walk forwards from it, and flip them round afterwards.
+			stmt += ` AND (s.created > ? OR (s.created = ? AND s.id > ?))`
+			order = `ORDER BY s.created ASC, s.id ASC`
+		} else {
+			stmt += ` AND (s.created < ? OR (s.created = ? AND s.id < ?))`
+		}
+		args = append(args, created, created, cursor.ID)
+	}
+	stmt += ` ` + order + ` LIMIT ?`
+	args = append(args, limit)
+
+	snippets, err := m.list(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor != nil && newer {
+		for i, j := 0, len(snippets)-1; i < j; i, j = i+1, j-1 {
+			snippets[i], snippets[j] = snippets[j], snippets[i]
+		}
+	}
+	return snippets, nil
+}
+
+// Tagged will return up to limit public snippets with the given tag, newest
+// first, skipping the first offset of them. Like Latest, snippets which burn
+// after reading are left out.
+func (m *SnippetModel) Tagged(tag string, limit, offset int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	INNER JOIN snippet_tags st ON st.snippet_id = s.id
+	INNER JOIN tags t ON t.id = st.tag_id
+	WHERE ` + notExpired + ` AND s.deleted IS NULL AND s.visibility = ?
+	AND s.burn_after_reading = FALSE AND t.name = ?
+	ORDER BY s.created DESC, s.id DESC LIMIT ? OFFSET ?`
+
+	return m.list(stmt, models.VisibilityPublic, tag, limit, offset)
+}
+
+// Search will return the given page of public snippets whose title or
+// content match the query, best match first, along with whether there are
+// any more pages after it. Pages are numbered from 1 and hold
+// models.SearchPageSize snippets each. Like Latest, snippets which burn after
+// reading are left out.
+func (m *SnippetModel) Search(query string, page int) ([]*models.Snippet, bool, error) {
+	// The FULLTEXT index on (title, content) is only used when MATCH names
+	// exactly those columns. We ask for one more snippet than fits on the
+	// page, to find out whether there is a next page.
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + notExpired + ` AND s.deleted IS NULL AND s.visibility = ?
+	AND s.burn_after_reading = FALSE AND s.hashed_password IS NULL AND s.ciphertext IS NULL
+	AND MATCH (s.title, s.content) AGAINST (? IN NATURAL LANGUAGE MODE)
+	ORDER BY MATCH (s.title, s.content) AGAINST (? IN NATURAL LANGUAGE MODE) DESC,
+	s.created DESC, s.id DESC
+	LIMIT ? OFFSET ?`
+
+	snippets, err := m.list(stmt, models.VisibilityPublic, query, query,
+		models.SearchPageSize+1, (page-1)*models.SearchPageSize)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(snippets) > models.SearchPageSize {
+		return snippets[:models.SearchPageSize], true, nil
+	}
+	return snippets, false, nil
+}
+
+// list will return the snippets selected by stmt, which must select the
+// snippetColumns, along with their tags.
+func (m *SnippetModel) list(stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err := rows.Scan(snippetFields(s)...)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = loadTags(m.DB, snippets); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// Star will record that the given user has starred a snippet. Starring a
+// snippet which the user has already starred does nothing.
+func (m *SnippetModel) Star(userID, snippetID int) error {
+	stmt := `INSERT IGNORE INTO stars (user_id, snippet_id, created)
+	VALUES (?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, snippetID)
+	return err
+}
+
+// Unstar will take away the given user's star from a snippet, if they had
+// starred it.
+func (m *SnippetModel) Unstar(userID, snippetID int) error {
+	_, err := m.DB.Exec(`DELETE FROM stars WHERE user_id = ? AND snippet_id = ?`, userID, snippetID)
+	return err
+}
+
+// Starred reports whether the given user has starred a snippet.
+func (m *SnippetModel) Starred(userID, snippetID int) (bool, error) {
+	stmt := `SELECT EXISTS(SELECT 1 FROM stars WHERE user_id = ? AND snippet_id = ?)`
+
+	var starred bool
+	err := m.DB.QueryRow(stmt, userID, snippetID).Scan(&starred)
+	return starred, err
+}
+
+// StarredBy is like List, but for the snippets the given user has starred
+// which they can still view: their own, and other people's public and
+// unlisted ones.
+func (m *SnippetModel) StarredBy(userID int, cursor *models.Cursor, newer bool, limit int) ([]*models.Snippet, error) {
+	cond := `s.id IN (SELECT snippet_id FROM stars WHERE user_id = ?)
+	AND (s.visibility <> ? OR s.user_id = ?)`
+
+	return m.page(cond, []interface{}{userID, models.VisibilityPrivate, userID}, cursor, newer, limit)
+}
+
+// InCollection will return the snippets in a collection, in the collection's
+// order, which the given user may see listed: their own, and other people's
+// public ones. Someone else's unlisted snippet is left out, as putting it in
+// a collection mustn't publish a link to it. A zero userID is for somebody
+// who isn't signed in.
+func (m *SnippetModel) InCollection(collectionID, userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	INNER JOIN collection_snippets cs ON cs.snippet_id = s.id
+	WHERE ` + notExpired + ` AND s.deleted IS NULL AND cs.collection_id = ?
+	AND (s.visibility = ? OR s.user_id = ?)
+	ORDER BY cs.position`
+
+	return m.list(stmt, collectionID, models.VisibilityPublic, userID)
+}
+
+// Popular will return up to limit public snippets with the most stars given
+// since the given time, most starred first. A zero since counts every star.
+// Snippets which weren't starred in that time are left out, as are those
+// which burn after reading.
+func (m *SnippetModel) Popular(since time.Time, limit int) ([]*models.Snippet, error) {
+	// Grouping by s.id is enough for MySQL to know that the rest of the
+	// selected columns, which depend on it, are the same within a group.
+	join := `INNER JOIN stars recent ON recent.snippet_id = s.id`
+	args := []interface{}{}
+	if !since.IsZero() {
+		join += ` AND recent.created >= ?`
+		args = append(args, since.UTC())
+	}
+	args = append(args, models.VisibilityPublic, limit)
+
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id ` + join + `
+	WHERE ` + notExpired + ` AND s.deleted IS NULL AND s.visibility = ?
+	AND s.burn_after_reading = FALSE
+	GROUP BY s.id
+	ORDER BY COUNT(*) DESC, s.created DESC, s.id DESC
+	LIMIT ?`
+
+	return m.list(stmt, args...)
+}
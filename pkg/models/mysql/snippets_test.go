@@ -0,0 +1,543 @@
+package mysql
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+func TestSnippetModelGet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	tests := []struct {
+		name        string
+		shortID     string
+		wantSnippet *models.Snippet
+		wantError   error
+	}{
+		{
+			name:    "Valid ID",
+			shortID: "aB3dE5gH7j",
+			wantSnippet: &models.Snippet{
+				ID:         1,
+				ShortID:    "aB3dE5gH7j",
+				UserID:     1,
+				Author:     "Alice Jones",
+				Title:      "An old silent pond",
+				Content:    "An old silent pond...",
+				Language:   "text",
+				Visibility: models.VisibilityPublic,
+				Tags:       []string{"haiku"},
+				Created:    time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC),
+				Expires:    time.Date(2099, 1, 1, 10, 0, 0, 0, time.UTC),
+			},
+			wantError: nil,
+		},
+		{
+			name:        "Non-existent ID",
+			shortID:     "zzzzzzzzzz",
+			wantSnippet: nil,
+			wantError:   models.ErrNoRecord,
+		},
+		{
+			name:        "ID in the wrong case",
+			shortID:     "Ab3De5Gh7J",
+			wantSnippet: nil,
+			wantError:   models.ErrNoRecord,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, teardown := newTestDB(t)
+			defer teardown()
+
+			m := SnippetModel{db}
+
+			snippet, err := m.Get(tt.shortID)
+
+			if err != tt.wantError {
+				t.Errorf("want %v; got %s", tt.wantError, err)
+			}
+
+			if !reflect.DeepEqual(snippet, tt.wantSnippet) {
+				t.Errorf("want %v; got %v", tt.wantSnippet, snippet)
+			}
+		})
+	}
+}
+
+func TestSnippetModelInsertFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{db}
+
+	files := []*models.File{
+		{Name: "run.sh", Language: "bash", Content: "#!/bin/sh\nexec ./web\n"},
+		{Name: "config.yaml", Language: "yaml", Content: "addr: \":4000\"\n"},
+	}
+
+	shortID, err := m.Insert(&models.Snippet{
+		UserID:     1,
+		Title:      "Web server",
+		Filename:   "Dockerfile",
+		Content:    "FROM golang:1.13\n",
+		Language:   "docker",
+		Files:      files,
+		Visibility: models.VisibilityPublic,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(shortID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Filename != "Dockerfile" {
+		t.Errorf("want filename %q; got %q", "Dockerfile", s.Filename)
+	}
+	if !reflect.DeepEqual(s.Files, files) {
+		t.Errorf("want files %v; got %v", files, s.Files)
+	}
+}
+
+func TestSnippetModelUnlock(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{db}
+
+	shortID, err := m.Insert(&models.Snippet{
+		UserID:     1,
+		Title:      "Staging database",
+		Content:    "postgres://staging",
+		Password:   "open sesame",
+		Visibility: models.VisibilityUnlisted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(shortID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Protected {
+		t.Errorf("want snippet to be protected")
+	}
+
+	tests := []struct {
+		name      string
+		id        int
+		password  string
+		wantError error
+	}{
+		{"Right password", s.ID, "open sesame", nil},
+		{"Wrong password", s.ID, "open barley", models.ErrInvalidCredentials},
+		{"Unprotected snippet", 1, "open sesame", models.ErrNoRecord},
+		{"Non-existent snippet", 99, "open sesame", models.ErrNoRecord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Unlock(tt.id, tt.password)
+			if err != tt.wantError {
+				t.Errorf("want %v; got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func TestSnippetModelInsertEncrypted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{db}
+
+	ciphertext := append(bytes.Repeat([]byte{1}, 12), 0, 0xff, 2, 3)
+	shortID, err := m.Insert(&models.Snippet{
+		UserID:     1,
+		Title:      "Production keys",
+		Ciphertext: ciphertext,
+		Visibility: models.VisibilityUnlisted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.Get(shortID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Encrypted {
+		t.Errorf("want snippet to be encrypted")
+	}
+	if !bytes.Equal(s.Ciphertext, ciphertext) {
+		t.Errorf("want %x; got %x", ciphertext, s.Ciphertext)
+	}
+
+	// An unencrypted snippet has no ciphertext.
+	s, err = m.Get("aB3dE5gH7j")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Encrypted || s.Ciphertext != nil {
+		t.Errorf("want snippet not to be encrypted")
+	}
+}
+
+func TestSnippetModelPurgeExpired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{db}
+
+	// The trash is kept for models.TrashRetentionDays, so with now at the
+	// start of June anything trashed before the 2nd of May is purged.
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	insertSnippets(t, db,
+		`'eXp1r3dXyz', 1, 'Expired', 'Expired', 'public', FALSE, '2020-01-01 10:00:00', '2020-05-01 10:00:00', NULL, NULL`,
+		`'oLdTr4sHxy', 1, 'Trashed long ago', 'Trashed', 'public', FALSE, '2020-01-01 10:00:00', NULL, '2020-04-01 10:00:00', NULL`,
+		`'nEwTr4sHxy', 1, 'Trashed lately', 'Trashed', 'public', FALSE, '2020-01-01 10:00:00', NULL, '2020-05-20 10:00:00', NULL`,
+		`'l1VeSn1pXy', 1, 'Live', 'Live', 'public', FALSE, '2020-01-01 10:00:00', NULL, NULL, NULL`,
+		// A fork of the expired snippet, which is 2.
+		`'f0RkEdXyzA', 1, 'Forked', 'Expired', 'public', FALSE, '2020-02-01 10:00:00', NULL, NULL, 2`,
+	)
+
+	// Purging goes in batches of up to limit snippets.
+	for _, want := range []int{1, 1, 0} {
+		n, err := m.PurgeExpired(now, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("want %d purged; got %d", want, n)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		shortID    string
+		wantExists bool
+	}{
+		{"Expired", "eXp1r3dXyz", false},
+		{"Trashed before the cutoff", "oLdTr4sHxy", false},
+		{"Trashed after the cutoff", "nEwTr4sHxy", true},
+		{"Live", "l1VeSn1pXy", true},
+		{"Not yet expired", "aB3dE5gH7j", true},
+		{"Fork of an expired snippet", "f0RkEdXyzA", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exists bool
+			err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM snippets WHERE short_id = ?)`, tt.shortID).Scan(&exists)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if exists != tt.wantExists {
+				t.Errorf("want exists %t; got %t", tt.wantExists, exists)
+			}
+		})
+	}
+
+	// The fork outlives the snippet it was forked from, but no longer points
+	// to it.
+	var parentID *int
+	err := db.QueryRow(`SELECT parent_id FROM snippets WHERE short_id = ?`, "f0RkEdXyzA").Scan(&parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parentID != nil {
+		t.Errorf("want parent_id NULL; got %d", *parentID)
+	}
+}
+
+// snippetIDs returns the IDs of the given snippets, in order.
+func snippetIDs(snippets []*models.Snippet) []int {
+	ids := []int{}
+	for _, s := range snippets {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+// insertStarredSnippets adds two more users and snippets of each kind for
+// them to star, to the test database:
+//
+//	2 public, by Bob
+//	3 unlisted, by Bob
+//	4 private, by Bob
+//	5 public, burnt after reading, by Bob
+//	6 public, in Bob's trash
+//	7 private, by Alice
+//
+// Snippet 1 has three stars from the start of the year, and the others are
+// starred since the start of May.
+func insertStarredSnippets(t *testing.T, db *sql.DB) {
+	_, err := db.Exec(`INSERT INTO users (name, email, hashed_password, created) VALUES
+	('Bob Smith', 'bob@example.com', '', '2018-12-24 10:00:00'),
+	('Carol White', 'carol@example.com', '', '2018-12-25 10:00:00')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insertSnippets(t, db,
+		`'pUbL1cXyzA', 2, 'Public', 'Public', 'public', FALSE, '2019-02-01 10:00:00', NULL, NULL, NULL`,
+		`'uNl1sTeDxy', 2, 'Unlisted', 'Unlisted', 'unlisted', FALSE, '2019-03-01 10:00:00', NULL, NULL, NULL`,
+		`'pR1vAtEXyz', 2, 'Private', 'Private', 'private', FALSE, '2019-04-01 10:00:00', NULL, NULL, NULL`,
+		`'bUrNXyzAbc', 2, 'Burn', 'Burn', 'public', TRUE, '2019-05-01 10:00:00', NULL, NULL, NULL`,
+		`'tR4sHeDXyz', 2, 'Trashed', 'Trashed', 'public', FALSE, '2019-06-01 10:00:00', NULL, '2019-06-02 10:00:00', NULL`,
+		`'aL1cEpRivX', 1, 'Alice private', 'Private', 'private', FALSE, '2019-07-01 10:00:00', NULL, NULL, NULL`,
+	)
+
+	_, err = db.Exec(`INSERT INTO stars (user_id, snippet_id, created) VALUES
+	(1, 1, '2020-01-01 10:00:00'), (2, 1, '2020-01-01 10:00:00'), (3, 1, '2020-01-01 10:00:00'),
+	(2, 2, '2020-05-02 10:00:00'), (3, 2, '2020-05-02 10:00:00'),
+	(1, 3, '2020-05-02 10:00:00'), (2, 3, '2020-05-02 10:00:00'), (3, 3, '2020-05-02 10:00:00'),
+	(1, 4, '2020-05-02 10:00:00'), (2, 4, '2020-05-02 10:00:00'), (3, 4, '2020-05-02 10:00:00'),
+	(1, 5, '2020-05-02 10:00:00'), (2, 5, '2020-05-02 10:00:00'), (3, 5, '2020-05-02 10:00:00'),
+	(1, 6, '2020-05-02 10:00:00'), (2, 6, '2020-05-02 10:00:00'), (3, 6, '2020-05-02 10:00:00'),
+	(1, 7, '2020-05-02 10:00:00')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnippetModelPopular(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	insertStarredSnippets(t, db)
+	m := SnippetModel{db}
+
+	// Unlisted, private, burn after reading and trashed snippets are never
+	// popular, however many stars they have.
+	tests := []struct {
+		name  string
+		since time.Time
+		limit int
+		want  []int
+	}{
+		{"Every star", time.Time{}, 10, []int{1, 2}},
+		{"Limited", time.Time{}, 1, []int{1}},
+		{"Since May", time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), 10, []int{2}},
+		{"Since June", time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), 10, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.Popular(tt.since, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := snippetIDs(snippets); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSnippetModelStarredBy(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	insertStarredSnippets(t, db)
+	m := SnippetModel{db}
+
+	// Snippet 5 was created on the 1st of May 2019.
+	fifth := &models.Cursor{Created: time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC), ID: 5}
+
+	// Nobody sees another person's private snippet or anything in the
+	// trash, but burn after reading snippets are listed here.
+	tests := []struct {
+		name   string
+		userID int
+		cursor *models.Cursor
+		newer  bool
+		limit  int
+		want   []int
+	}{
+		{"Alice", 1, nil, false, 10, []int{7, 5, 3, 1}},
+		{"Bob", 2, nil, false, 10, []int{5, 4, 3, 2, 1}},
+		{"Limited", 1, nil, false, 2, []int{7, 5}},
+		{"Older", 1, fifth, false, 10, []int{3, 1}},
+		{"Newer", 1, fifth, true, 10, []int{7}},
+		{"No stars", 4, nil, false, 10, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.StarredBy(tt.userID, tt.cursor, tt.newer, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := snippetIDs(snippets); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSnippetModelList(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	// Snippets 3 and 4 are created at the same moment, so that only their
+	// IDs tell them apart. Snippets 6 and 7 are never listed.
+	insertSnippets(t, db,
+		`'sEc0nDXyzA', 1, 'Second', 'Second', 'public', FALSE, '2019-02-01 10:00:00', NULL, NULL, NULL`,
+		`'tH1rDXyzAb', 1, 'Third', 'Third', 'public', FALSE, '2019-03-01 10:00:00', NULL, NULL, NULL`,
+		`'f0uRtHXyzA', 1, 'Fourth', 'Fourth', 'public', FALSE, '2019-03-01 10:00:00', NULL, NULL, NULL`,
+		`'f1FtHXyzAb', 1, 'Fifth', 'Fifth', 'public', FALSE, '2019-04-01 10:00:00', NULL, NULL, NULL`,
+		`'pR1vAtEXyz', 1, 'Private', 'Private', 'private', FALSE, '2019-05-01 10:00:00', NULL, NULL, NULL`,
+		`'bUrNXyzAbc', 1, 'Burn', 'Burn', 'public', TRUE, '2019-06-01 10:00:00', NULL, NULL, NULL`,
+	)
+	m := SnippetModel{db}
+
+	cursor := func(id int) *models.Cursor {
+		created := map[int]time.Time{
+			1: time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC),
+			3: time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC),
+			4: time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC),
+			5: time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC),
+		}
+		return &models.Cursor{Created: created[id], ID: id}
+	}
+
+	tests := []struct {
+		name   string
+		cursor *models.Cursor
+		newer  bool
+		limit  int
+		want   []int
+	}{
+		{"Everything", nil, false, 10, []int{5, 4, 3, 2, 1}},
+		{"First page", nil, false, 2, []int{5, 4}},
+		{"Older, past a tie", cursor(4), false, 2, []int{3, 2}},
+		{"Older, from the last", cursor(1), false, 2, []int{}},
+		{"Newer, past a tie", cursor(3), true, 2, []int{5, 4}},
+		{"Newer, just the next one", cursor(3), true, 1, []int{4}},
+		{"Newer, from the first", cursor(5), true, 2, []int{}},
+		{"Newer, short page", cursor(1), true, 10, []int{5, 4, 3, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.List(tt.cursor, tt.newer, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := snippetIDs(snippets); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	// Snippets 2 to 13 are public and all say the same thing, so they're as
+	// good a match as each other and come newest first. Snippets 14 to 20 say
+	// it too, but are never found.
+	var rows []string
+	for i := 1; i <= 12; i++ {
+		rows = append(rows, fmt.Sprintf(
+			`'l0tUs%05d', 1, 'Lotus', 'The lotus flower', 'public', FALSE, '2019-02-%02d 10:00:00', NULL, NULL, NULL`, i, i))
+	}
+	rows = append(rows,
+		`'uNl1sTeDxy', 1, 'Lotus', 'The lotus flower', 'unlisted', FALSE, '2019-03-01 10:00:00', NULL, NULL, NULL`,
+		`'pR1vAtEXyz', 1, 'Lotus', 'The lotus flower', 'private', FALSE, '2019-03-01 10:00:00', NULL, NULL, NULL`,
+		`'bUrNXyzAbc', 1, 'Lotus', 'The lotus flower', 'public', TRUE, '2019-03-01 10:00:00', NULL, NULL, NULL`,
+		`'tR4sHeDXyz', 1, 'Lotus', 'The lotus flower', 'public', FALSE, '2019-03-01 10:00:00', NULL, '2019-03-02 10:00:00', NULL`,
+		`'eXp1r3dXyz', 1, 'Lotus', 'The lotus flower', 'public', FALSE, '2019-03-01 10:00:00', '2019-03-02 10:00:00', NULL, NULL`,
+		`'l0cKeDXyzA', 1, 'Lotus', 'The lotus flower', 'public', FALSE, '2019-03-01 10:00:00', NULL, NULL, NULL`,
+		`'eNcRyPtEdX', 1, 'Lotus', 'The lotus flower', 'public', FALSE, '2019-03-01 10:00:00', NULL, NULL, NULL`,
+	)
+	insertSnippets(t, db, rows...)
+
+	_, err := db.Exec(`UPDATE snippets SET hashed_password = ? WHERE short_id = 'l0cKeDXyzA'`,
+		"$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`UPDATE snippets SET ciphertext = ? WHERE short_id = 'eNcRyPtEdX'`, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := SnippetModel{db}
+
+	tests := []struct {
+		name     string
+		query    string
+		page     int
+		want     []int
+		wantMore bool
+	}{
+		{"First page", "lotus", 1, []int{13, 12, 11, 10, 9, 8, 7, 6, 5, 4}, true},
+		{"Last page", "lotus", 2, []int{3, 2}, false},
+		{"Past the last page", "lotus", 3, []int{}, false},
+		{"Other words", "Pond", 1, []int{1}, false},
+		{"No match", "autumn", 1, []int{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, more, err := m.Search(tt.query, tt.page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := snippetIDs(snippets); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+			if more != tt.wantMore {
+				t.Errorf("want more %t; got %t", tt.wantMore, more)
+			}
+		})
+	}
+}
@@ -1,12 +1,160 @@
 CREATE TABLE snippets (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    short_id CHAR(10) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+    user_id INTEGER NOT NULL,
     title VARCHAR(100) NOT NULL,
+    filename VARCHAR(100) NOT NULL DEFAULT '',
     content TEXT NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    hashed_password CHAR(60) NULL,
+    ciphertext BLOB NULL,
     created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
+    expires DATETIME NULL,
+    deleted DATETIME NULL,
+    parent_id INTEGER NULL
 );
 
 CREATE INDEX idx_snippets_created ON snippets(created);
+CREATE INDEX idx_snippets_user_id_created ON snippets(user_id, created);
+CREATE INDEX idx_snippets_expires ON snippets(expires);
+CREATE INDEX idx_snippets_deleted ON snippets(deleted);
+CREATE FULLTEXT INDEX idx_snippets_title_content ON snippets(title, content);
+
+CREATE INDEX idx_snippets_parent_id ON snippets(parent_id);
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_short_id UNIQUE (short_id);
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_parent_id
+    FOREIGN KEY (parent_id) REFERENCES snippets(id) ON DELETE SET NULL;
+
+CREATE TABLE snippet_revisions (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_snippet_revisions_snippet_id ON snippet_revisions(snippet_id);
+
+ALTER TABLE snippet_revisions ADD CONSTRAINT snippet_revisions_fk_snippet_id
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE comments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    parent_id INTEGER NULL,
+    revision_id INTEGER NULL,
+    line_start INTEGER NULL,
+    line_end INTEGER NULL,
+    body TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    edited DATETIME NULL
+);
+
+CREATE INDEX idx_comments_snippet_id ON comments(snippet_id);
+
+ALTER TABLE comments ADD CONSTRAINT comments_fk_snippet_id
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+ALTER TABLE comments ADD CONSTRAINT comments_fk_parent_id
+    FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
+ALTER TABLE comments ADD CONSTRAINT comments_fk_revision_id
+    FOREIGN KEY (revision_id) REFERENCES snippet_revisions(id) ON DELETE CASCADE;
+
+CREATE TABLE stars (
+    user_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_stars_snippet_id_created ON stars(snippet_id, created);
+
+ALTER TABLE stars ADD CONSTRAINT stars_fk_snippet_id
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE snippet_files (
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    PRIMARY KEY (snippet_id, position)
+);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_fk_snippet_id
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE snippet_views (
+    snippet_id INTEGER NOT NULL,
+    day DATE NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, day)
+);
+
+ALTER TABLE snippet_views ADD CONSTRAINT snippet_views_fk_snippet_id
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE snippet_referrers (
+    snippet_id INTEGER NOT NULL,
+    day DATE NOT NULL,
+    domain VARCHAR(255) NOT NULL,
+    views INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, day, domain)
+);
+
+ALTER TABLE snippet_referrers ADD CONSTRAINT snippet_referrers_fk_snippet_id
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE collections (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    short_id CHAR(10) CHARACTER SET ascii COLLATE ascii_bin NOT NULL,
+    user_id INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public',
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_collections_user_id_name ON collections(user_id, name);
+
+ALTER TABLE collections ADD CONSTRAINT collections_uc_short_id UNIQUE (short_id);
+
+CREATE TABLE collection_snippets (
+    collection_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, snippet_id)
+);
+
+CREATE INDEX idx_collection_snippets_snippet_id ON collection_snippets(snippet_id);
+
+ALTER TABLE collection_snippets ADD CONSTRAINT collection_snippets_fk_collection_id
+    FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE;
+ALTER TABLE collection_snippets ADD CONSTRAINT collection_snippets_fk_snippet_id
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE tags (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(32) NOT NULL
+);
+
+ALTER TABLE tags ADD CONSTRAINT tags_uc_name UNIQUE (name);
+
+CREATE TABLE snippet_tags (
+    snippet_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (snippet_id, tag_id)
+);
+
+CREATE INDEX idx_snippet_tags_tag_id ON snippet_tags(tag_id);
+
+ALTER TABLE snippet_tags ADD CONSTRAINT snippet_tags_fk_snippet_id
+    FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+ALTER TABLE snippet_tags ADD CONSTRAINT snippet_tags_fk_tag_id
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE;
 
 CREATE TABLE users (
     id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
@@ -23,4 +171,46 @@ INSERT INTO users (name, email, hashed_password, created) VALUES (
     'alice@example.com',
     '$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG',
     '2018-12-23 17:25:22'
-);
\ No newline at end of file
+);
+INSERT INTO snippets (short_id, user_id, title, content, language, created, expires) VALUES (
+    'aB3dE5gH7j',
+    1,
+    'An old silent pond',
+    'An old silent pond...',
+    'text',
+    '2019-01-01 10:00:00',
+    '2099-01-01 10:00:00'
+);
+
+INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created) VALUES (
+    1,
+    1,
+    'An old silent pond',
+    'An old silent pond...',
+    '2019-01-01 10:00:00'
+);
+
+INSERT INTO tags (name) VALUES ('haiku');
+
+INSERT INTO snippet_tags (snippet_id, tag_id) VALUES (1, 1);
+
+INSERT INTO comments (snippet_id, user_id, parent_id, body, created) VALUES
+    (1, 1, NULL, 'Written on a rainy afternoon.', '2019-01-01 11:00:00'),
+    (1, 1, 1, 'It stopped raining later.', '2019-01-01 12:00:00');
+
+INSERT INTO comments (snippet_id, user_id, revision_id, line_start, line_end, body, created) VALUES
+    (1, 1, 1, 1, 1, 'Which pond?', '2019-01-01 13:00:00');
+
+INSERT INTO snippet_views (snippet_id, day, views) VALUES
+    (1, '2019-01-01', 5),
+    (1, '2019-01-02', 3);
+
+INSERT INTO snippet_referrers (snippet_id, day, domain, views) VALUES
+    (1, '2019-01-01', 'news.ycombinator.com', 2),
+    (1, '2019-01-02', 'news.ycombinator.com', 1),
+    (1, '2019-01-02', 'reddit.com', 2);
+
+INSERT INTO collections (short_id, user_id, name, visibility, created) VALUES
+    ('cOlLeCt10n', 1, 'Haiku', 'public', '2019-01-02 10:00:00');
+
+INSERT INTO collection_snippets (collection_id, snippet_id, position) VALUES (1, 1, 1);
@@ -1,3 +1,23 @@
 DROP TABLE users;
 
+DROP TABLE comments;
+
+DROP TABLE snippet_revisions;
+
+DROP TABLE stars;
+
+DROP TABLE collection_snippets;
+
+DROP TABLE collections;
+
+DROP TABLE snippet_files;
+
+DROP TABLE snippet_views;
+
+DROP TABLE snippet_referrers;
+
+DROP TABLE snippet_tags;
+
+DROP TABLE tags;
+
 DROP TABLE snippets;
\ No newline at end of file
@@ -44,3 +44,23 @@ func newTestDB(t *testing.T) (*sql.DB, func()) {
 		db.Close()
 	}
 }
+
+// insertSnippets adds snippets to the test database, on top of the ones in
+// setup.sql. Each row gives the values for (short_id, user_id, title,
+// content, visibility, burn_after_reading, created, expires, deleted,
+// parent_id), written out in SQL. Snippets are numbered in the order they're
+// added, carrying on from the last one in setup.sql.
+func insertSnippets(t *testing.T, db *sql.DB, rows ...string) {
+	stmt := `INSERT INTO snippets (short_id, user_id, title, content, visibility,
+	burn_after_reading, created, expires, deleted, parent_id) VALUES `
+	for i, row := range rows {
+		if i > 0 {
+			stmt += `, `
+		}
+		stmt += `(` + row + `)`
+	}
+
+	if _, err := db.Exec(stmt); err != nil {
+		t.Fatal(err)
+	}
+}
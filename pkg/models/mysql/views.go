@@ -0,0 +1,117 @@
+package mysql
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+// ViewModel defines a type which wraps a sql.DB connection pool. Views are
+// only ever stored as daily totals for each snippet, and for each snippet and
+// referring domain, never one row per view.
+type ViewModel struct {
+	DB *sql.DB
+}
+
+// Add will add counts of views to the daily totals, all in one transaction.
+// Views of snippets which no longer exist are dropped.
+func (m *ViewModel) Add(counts []*models.ViewCount) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Selecting the snippet's ID, rather than giving it as a value, means
+	// nothing is inserted for a snippet which has been purged since it was
+	// viewed, instead of the foreign key failing the whole transaction.
+	viewsStmt := `INSERT INTO snippet_views (snippet_id, day, views)
+	SELECT id, ?, ? FROM snippets WHERE id = ?
+	ON DUPLICATE KEY UPDATE views = views + ?`
+	referrersStmt := `INSERT INTO snippet_referrers (snippet_id, day, domain, views)
+	SELECT id, ?, ?, ? FROM snippets WHERE id = ?
+	ON DUPLICATE KEY UPDATE views = views + ?`
+
+	for _, c := range counts {
+		day := c.Day.UTC().Format("2006-01-02")
+		_, err = tx.Exec(viewsStmt, day, c.Views, c.SnippetID, c.Views)
+		if err != nil {
+			return err
+		}
+		if c.Domain == "" {
+			continue
+		}
+		_, err = tx.Exec(referrersStmt, day, c.Domain, c.Views, c.SnippetID, c.Views)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Daily will return the number of views of a snippet on each day since the
+// given day, oldest first. Days without any views are left out.
+func (m *ViewModel) Daily(snippetID int, since time.Time) ([]*models.DailyViews, error) {
+	stmt := `SELECT day, views FROM snippet_views
+	WHERE snippet_id = ? AND day >= ? ORDER BY day`
+
+	rows, err := m.DB.Query(stmt, snippetID, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []*models.DailyViews{}
+	for rows.Next() {
+		d := &models.DailyViews{}
+		err := rows.Scan(&d.Day, &d.Views)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
+
+// Referrers will return up to limit of the domains which visitors to a
+// snippet came from since the given day, the most views first.
+func (m *ViewModel) Referrers(snippetID int, since time.Time, limit int) ([]*models.ReferrerViews, error) {
+	stmt := `SELECT domain, SUM(views) AS total FROM snippet_referrers
+	WHERE snippet_id = ? AND day >= ?
+	GROUP BY domain ORDER BY total DESC, domain LIMIT ?`
+
+	rows, err := m.DB.Query(stmt, snippetID, since.UTC().Format("2006-01-02"), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	referrers := []*models.ReferrerViews{}
+	for rows.Next() {
+		r := &models.ReferrerViews{}
+		err := rows.Scan(&r.Domain, &r.Views)
+		if err != nil {
+			return nil, err
+		}
+		referrers = append(referrers, r)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return referrers, nil
+}
+
+// Total will return the number of views of a snippet ever.
+func (m *ViewModel) Total(snippetID int) (int, error) {
+	var total int
+	stmt := `SELECT COALESCE(SUM(views), 0) FROM snippet_views WHERE snippet_id = ?`
+	err := m.DB.QueryRow(stmt, snippetID).Scan(&total)
+	return total, err
+}
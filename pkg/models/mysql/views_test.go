@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cedrickchee/snippetbox/pkg/models"
+)
+
+func TestViewModelAdd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := ViewModel{db}
+
+	jan2 := time.Date(2019, 1, 2, 15, 30, 0, 0, time.UTC)
+	jan3 := time.Date(2019, 1, 3, 9, 0, 0, 0, time.UTC)
+	err := m.Add([]*models.ViewCount{
+		{SnippetID: 1, Day: jan2, Views: 1},
+		{SnippetID: 1, Day: jan2, Domain: "reddit.com", Views: 2},
+		{SnippetID: 1, Day: jan3, Domain: "lobste.rs", Views: 4},
+		// Views of a snippet which doesn't exist are dropped.
+		{SnippetID: 99, Day: jan3, Domain: "lobste.rs", Views: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	days, err := m.Daily(1, jan2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDays := []*models.DailyViews{
+		{Day: time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC), Views: 6},
+		{Day: time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC), Views: 4},
+	}
+	if !reflect.DeepEqual(days, wantDays) {
+		t.Errorf("want %v; got %v", wantDays, days)
+	}
+
+	referrers, err := m.Referrers(1, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantReferrers := []*models.ReferrerViews{
+		{Domain: "lobste.rs", Views: 4},
+		{Domain: "reddit.com", Views: 4},
+	}
+	if !reflect.DeepEqual(referrers, wantReferrers) {
+		t.Errorf("want %v; got %v", wantReferrers, referrers)
+	}
+
+	total, err := m.Total(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 15 {
+		t.Errorf("want 15; got %d", total)
+	}
+}
+
+func TestViewModelTotal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+
+	tests := []struct {
+		name      string
+		snippetID int
+		want      int
+	}{
+		{"Viewed", 1, 8},
+		{"Never viewed", 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, teardown := newTestDB(t)
+			defer teardown()
+
+			m := ViewModel{db}
+
+			total, err := m.Total(tt.snippetID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tt.want {
+				t.Errorf("want %d; got %d", tt.want, total)
+			}
+		})
+	}
+}
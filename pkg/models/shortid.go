@@ -0,0 +1,57 @@
+package models
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// ShortIDLength is the number of characters in a snippet's short ID.
+const ShortIDLength = 10
+
+const shortIDAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// NewShortID returns a random, URL-safe base62 identifier for a snippet. With
+// 62^10 possible values they can't feasibly be enumerated, unlike the
+// sequential integer IDs.
+//
+// A short ID always contains at least one letter, so that it can never be
+// mistaken for one of the old integer IDs.
+func NewShortID() (string, error) {
+	max := big.NewInt(int64(len(shortIDAlphabet)))
+	b := make([]byte, ShortIDLength)
+	for {
+		for i := range b {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			b[i] = shortIDAlphabet[n.Int64()]
+		}
+		if !isDigits(string(b)) {
+			return string(b), nil
+		}
+	}
+}
+
+// IsShortID reports whether s is well-formed as a short ID. It doesn't check
+// that any snippet actually has that ID.
+func IsShortID(s string) bool {
+	if len(s) != ShortIDLength || isDigits(s) {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package models
+
+import "testing"
+
+func TestNewShortID(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id, err := NewShortID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsShortID(id) {
+			t.Errorf("%q is not a valid short ID", id)
+		}
+		if seen[id] {
+			t.Errorf("%q was generated twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestIsShortID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{"Valid", "aB3dE5gH7j", true},
+		{"Too short", "aB3dE5gH7", false},
+		{"Too long", "aB3dE5gH7jk", false},
+		{"All digits", "1234567890", false},
+		{"Not base62", "aB3dE5gH7-", false},
+		{"Empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsShortID(tt.id); got != tt.want {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -5,4 +5,182 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// Highlight the lines picked out by an anchor like "#L12" or "#L12-L20", and
+// fill them in on the comment form. The lines of a snippet's further files
+// have their file's name in front, like "#run.sh-L12-L20". They're
+// highlighted too, but comments can only be on the main file's lines.
+// Shift-clicking a line number extends the current selection to that line,
+// within the same file.
+var lineAnchor = /^#(.*?)L(\d+)(?:-L(\d+))?$/;
+
+function selectedLines() {
+	var match = lineAnchor.exec(window.location.hash);
+	if (!match) {
+		return null;
+	}
+	var start = parseInt(match[2], 10);
+	var end = match[3] ? parseInt(match[3], 10) : start;
+	return end < start ? null : {prefix: match[1], start: start, end: end};
+}
+
+function highlightLines() {
+	var highlighted = document.querySelectorAll(".chroma .line.hl");
+	for (var i = 0; i < highlighted.length; i++) {
+		highlighted[i].classList.remove("hl");
+	}
+
+	var lines = selectedLines();
+	if (!lines) {
+		return;
+	}
+	for (var n = lines.start; n <= lines.end; n++) {
+		var number = document.getElementById(lines.prefix + "L" + n);
+		if (number) {
+			number.parentNode.classList.add("hl");
+		}
+	}
+
+	var field = document.getElementById("comment-lines");
+	if (field && !lines.prefix) {
+		field.value = lines.start == lines.end ? lines.start : lines.start + "-" + lines.end;
+	}
+}
+
+var lineLinks = document.querySelectorAll(".chroma .ln a");
+for (var i = 0; i < lineLinks.length; i++) {
+	lineLinks[i].addEventListener("click", function(e) {
+		var lines = selectedLines();
+		var match = /^(.*)L(\d+)$/.exec(this.parentNode.id);
+		if (!e.shiftKey || !lines || !match || match[1] != lines.prefix) {
+			return;
+		}
+		e.preventDefault();
+		var n = parseInt(match[2], 10);
+		var start = Math.min(lines.start, n);
+		var end = Math.max(lines.start, n);
+		window.location.hash = lines.prefix + (start == end ? "L" + start : "L" + start + "-L" + end);
+	});
+}
+
+window.addEventListener("hashchange", highlightLines);
+highlightLines();
+
+// Encrypted snippets are encrypted and decrypted here, with AES-GCM. The
+// server only ever sees an envelope, "v1.<iv>.<ciphertext>", and the key is
+// kept in the fragment of the snippet's link, which is never sent to it.
+function toBase64URL(bytes) {
+	var s = "";
+	for (var i = 0; i < bytes.length; i++) {
+		s += String.fromCharCode(bytes[i]);
+	}
+	return btoa(s).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function fromBase64URL(s) {
+	s = s.replace(/-/g, "+").replace(/_/g, "/");
+	while (s.length % 4) {
+		s += "=";
+	}
+	var bin = atob(s);
+	var bytes = new Uint8Array(bin.length);
+	for (var i = 0; i < bin.length; i++) {
+		bytes[i] = bin.charCodeAt(i);
+	}
+	return bytes;
+}
+
+var encryptedContent = document.getElementById("encrypted-content");
+if (encryptedContent) {
+	// The key is remembered for the rest of the session, so that the snippet
+	// can still be read after following a link on the page which replaces
+	// the fragment, such as the one back to a new comment.
+	var storageKey = "key:" + encryptedContent.getAttribute("data-short-id");
+	var key = window.location.hash.slice(1);
+	// Only the main file's line anchors are checked for, as a key could
+	// happen to look like one with a file's name in front.
+	var lines = selectedLines();
+	if (!key || (lines && !lines.prefix) || key.indexOf("comment-") == 0) {
+		key = sessionStorage.getItem(storageKey);
+	}
+	if (key) {
+		var parts = encryptedContent.getAttribute("data-envelope").split(".");
+		Promise.resolve().then(function() {
+			return crypto.subtle.importKey("raw", fromBase64URL(key), "AES-GCM", false, ["decrypt"]);
+		}).then(function(k) {
+			return crypto.subtle.decrypt({name: "AES-GCM", iv: fromBase64URL(parts[1])}, k, fromBase64URL(parts[2]));
+		}).then(function(plaintext) {
+			encryptedContent.textContent = new TextDecoder().decode(plaintext);
+			encryptedContent.classList.add("decrypted");
+			sessionStorage.setItem(storageKey, key);
+		}).catch(function() {
+			encryptedContent.textContent = "This snippet couldn't be decrypted. Check that you have the whole link, including the part after the #.";
+		});
+	}
+}
+
+var createForm = document.getElementById("create-form");
+if (createForm) {
+	createForm.addEventListener("submit", function(e) {
+		var encrypt = document.getElementById("encrypt");
+		if (!encrypt || !encrypt.checked) {
+			return;
+		}
+		e.preventDefault();
+
+		// The buttons to add and remove files would send the content to the
+		// server in plain text, and only one file can be encrypted anyway.
+		var contents = createForm.querySelectorAll("textarea[name=content]");
+		if ((e.submitter && e.submitter.name) || contents.length != 1) {
+			alert("An encrypted snippet can only have one file.");
+			return;
+		}
+
+		// Burn after reading and password protected snippets are opened
+		// through a form, which would lose the key in the fragment.
+		var burn = createForm.querySelector("input[name=burn]");
+		var password = createForm.querySelector("input[name=password]");
+		if ((burn && burn.checked) || (password && password.value)) {
+			alert("An encrypted snippet can't be burnt after reading or have a password.");
+			return;
+		}
+
+		var params = new URLSearchParams(new FormData(createForm));
+		var plaintext = new TextEncoder().encode(params.get("content"));
+		params.delete("content");
+		var iv = crypto.getRandomValues(new Uint8Array(12));
+		var rawKey;
+
+		crypto.subtle.generateKey({name: "AES-GCM", length: 256}, true, ["encrypt"]).then(function(k) {
+			return Promise.all([
+				crypto.subtle.exportKey("raw", k),
+				crypto.subtle.encrypt({name: "AES-GCM", iv: iv}, k, plaintext),
+			]);
+		}).then(function(results) {
+			rawKey = new Uint8Array(results[0]);
+			params.set("ciphertext", "v1." + toBase64URL(iv) + "." + toBase64URL(new Uint8Array(results[1])));
+			return fetch(createForm.action, {method: "POST", body: params, credentials: "same-origin"});
+		}).then(function(response) {
+			// On success we're redirected to the new snippet, which is then
+			// opened with the key in the fragment. Otherwise the form comes
+			// back with errors, which are shown without leaving the page, so
+			// that the plain text isn't lost.
+			if (response.redirected) {
+				window.location = response.url + "#" + toBase64URL(rawKey);
+				return;
+			}
+			return response.text().then(function(html) {
+				var page = new DOMParser().parseFromString(html, "text/html");
+				var errors = page.querySelectorAll(".error");
+				var messages = [];
+				for (var i = 0; i < errors.length; i++) {
+					messages.push(errors[i].textContent.trim());
+				}
+				alert(messages.join("\n") || "The snippet couldn't be saved.");
+			});
+		}).catch(function() {
+			alert("The snippet couldn't be encrypted.");
+		});
+	});
+}